@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/spf13/cobra"
+)
+
+// agentSocketEnv names the environment variable holding the agent socket,
+// both for the agent and for the invocations that use it.
+const agentSocketEnv = "AZKEYGET_AGENT_SOCK"
+
+// agentTimeout bounds a single exchange with the agent.
+const agentTimeout = time.Minute
+
+var (
+	agentSocket string
+	agentTTL    string
+)
+
+// agentRequest asks the agent for a secret. It is sent as a line of JSON.
+type agentRequest struct {
+	Vault   string `json:"vault"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// agentResponse answers an agentRequest with either the secret or the failure
+// the agent ran into, with its class.
+type agentResponse struct {
+	Secret *azsecrets.Secret `json:"secret,omitempty"`
+	Error  *errorReport      `json:"error,omitempty"`
+}
+
+// agent serves secrets from Key Vault with a single credential, keeping
+// retrieved secrets in memory for ttl.
+type agent struct {
+	clients *vaultClients
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	secrets map[secretRef]agentEntry
+}
+
+// agentEntry is a secret held by the agent.
+type agentEntry struct {
+	secret  azsecrets.Secret
+	fetched time.Time
+}
+
+func newAgentCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "agent",
+		Short: "Serve secrets to other invocations over a Unix socket",
+		Long: `Run in the foreground, authenticating once and serving secrets over a Unix
+domain socket that only the current user can access. Invocations that retrieve
+secrets, including exec and render, ask the agent instead of authenticating
+themselves when AZKEYGET_AGENT_SOCK is set to its socket, and fall back to
+Key Vault when no agent is listening.
+
+The agent authenticates with its own flags; those of the invocations using it
+are ignored. Retrieved secrets are kept in memory for --ttl. The agent stops on
+SIGINT or SIGTERM.`,
+		Args: usageArgs(cobra.NoArgs),
+		RunE: runAgent,
+	}
+
+	cmd.Flags().StringVar(&agentSocket, "socket", getEnvOrDefault(agentSocketEnv, ""), "Unix socket to listen on (required, env: "+agentSocketEnv+")")
+	cmd.Flags().StringVar(&agentTTL, "ttl", "5m", "How long retrieved secrets are kept in memory; 0 disables caching")
+
+	return cmd
+}
+
+func runAgent(cmd *cobra.Command, _ []string) error {
+	if err := requireFlags(cmd, "socket"); err != nil {
+		return err
+	}
+	ttl, err := parseDuration(agentTTL)
+	if err != nil || ttl < 0 {
+		return usageErrorf("invalid --ttl '%s': expected a duration such as 30s, 5m or 1h", agentTTL)
+	}
+
+	setupDebugLogging()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return serveAgent(ctx, cmd.OutOrStdout(), agentSocket, ttl)
+}
+
+// serveAgent authenticates, then serves secrets on socket until ctx is done.
+func serveAgent(ctx context.Context, out io.Writer, socket string, ttl time.Duration) error {
+	debugLog("Creating credential with method: %s", authMethod)
+	credential, err := newCredential(flagAuthSettings())
+	if err != nil {
+		debugLog("Failed to create credential: %v", err)
+		return withClass(classAuth, fmt.Errorf("failed to create credential: %w", err))
+	}
+	clients := newVaultClients()
+	clients.newCredential = func() (azcore.TokenCredential, error) {
+		return credential, nil
+	}
+
+	listener, err := listenAgent(socket)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Serving secrets on %s\n", socket)
+	return (&agent{clients: clients, ttl: ttl, secrets: map[secretRef]agentEntry{}}).serve(ctx, listener)
+}
+
+// listenAgent listens on socket, replacing a socket left behind by an agent
+// that is no longer running. Only the current user may connect.
+func listenAgent(socket string) (net.Listener, error) {
+	if info, err := os.Lstat(socket); err == nil {
+		if info.Mode()&fs.ModeSocket == 0 {
+			return nil, usageErrorf("'%s' exists and is not a socket", socket)
+		}
+		if conn, err := net.DialTimeout("unix", socket, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("an agent is already listening on %s", socket)
+		}
+		debugLog("Removing stale agent socket %s", socket)
+		if err := os.Remove(socket); err != nil {
+			return nil, fmt.Errorf("failed to remove stale agent socket: %w", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(socket), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create agent socket directory: %w", err)
+	}
+
+	listener, err := listenUnix(socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", socket, err)
+	}
+	debugLog("Listening on %s", socket)
+	return listener, nil
+}
+
+// serve accepts connections until ctx is done, then closes the listener,
+// which removes the socket.
+func (a *agent) serve(ctx context.Context, listener net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				debugLog("Agent stopped")
+				return nil
+			}
+			return fmt.Errorf("failed to accept agent connection: %w", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.handle(ctx, conn)
+		}()
+	}
+}
+
+// handle answers the requests on one connection, one JSON line each.
+func (a *agent) handle(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	encoder := json.NewEncoder(conn)
+	for {
+		if err := conn.SetDeadline(time.Now().Add(agentTimeout)); err != nil {
+			return
+		}
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				debugLog("Failed to read agent request: %v", err)
+			}
+			return
+		}
+		var request agentRequest
+		var response agentResponse
+		if err := json.Unmarshal(line, &request); err != nil {
+			report := classifyError(usageErrorf("invalid agent request: %v", err))
+			response.Error = &report
+		} else {
+			response = a.lookup(ctx, request)
+		}
+		if err := encoder.Encode(response); err != nil {
+			debugLog("Failed to write agent response: %v", err)
+			return
+		}
+	}
+}
+
+// lookup serves a secret from memory while it is fresh and usable, and from
+// Key Vault otherwise.
+func (a *agent) lookup(ctx context.Context, request agentRequest) agentResponse {
+	if request.Vault == "" || request.Name == "" {
+		report := classifyError(usageErrorf("agent request needs a vault and a secret name"))
+		return agentResponse{Error: &report}
+	}
+	ref := secretRef{vault: request.Vault, name: request.Name, version: request.Version}
+	// Vault URLs and secret names are case-insensitive
+	key := secretRef{vault: normalizeVault(ref.vault), name: strings.ToLower(ref.name), version: ref.version}
+
+	now := cacheClock()
+	a.mu.Lock()
+	entry, ok := a.secrets[key]
+	a.mu.Unlock()
+	if ok && now.Sub(entry.fetched) < a.ttl && usable(entry.secret, now) {
+		debugLog("Serving secret '%s' from memory", ref.name)
+		return agentResponse{Secret: &entry.secret}
+	}
+
+	result := fetchSecret(ctx, a.clients, ref)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if result.err != nil {
+		report := classifyError(result.err)
+		// Versions of a secret that was deleted, disabled or made
+		// inaccessible must not be served later
+		switch report.Code {
+		case classNotFound.code, classDisabled.code, classForbidden.code:
+			for cached := range a.secrets {
+				if cached.vault == key.vault && cached.name == key.name {
+					delete(a.secrets, cached)
+				}
+			}
+		}
+		return agentResponse{Error: &report}
+	}
+	if a.ttl > 0 {
+		a.secrets[key] = agentEntry{secret: result.secret, fetched: now}
+	}
+	return agentResponse{Secret: &result.secret}
+}
+
+// fetchFromAgent asks the agent listening on socket for a secret. It reports
+// false when no agent could be reached, so that the secret is retrieved
+// directly instead. A socket owned by another user is refused.
+func fetchFromAgent(ctx context.Context, socket string, ref secretRef) (secretResult, bool) {
+	name := ref.name
+	if err := checkAgentSocketOwner(socket); err != nil {
+		return secretResult{name: name, err: err}, true
+	}
+	dialer := net.Dialer{Timeout: 2 * time.Second}
+	conn, err := dialer.DialContext(ctx, "unix", socket)
+	if err != nil {
+		debugLog("No agent reachable at %s, retrieving secret '%s' directly: %v", socket, name, err)
+		return secretResult{}, false
+	}
+	defer conn.Close()
+
+	debugLog("Retrieving secret '%s' from the agent", name)
+	if err := conn.SetDeadline(time.Now().Add(agentTimeout)); err != nil {
+		return secretResult{name: name, err: fmt.Errorf("failed to get secret '%s' from agent: %w", name, err)}, true
+	}
+	var response agentResponse
+	err = json.NewEncoder(conn).Encode(agentRequest{Vault: ref.vault, Name: ref.name, Version: ref.version})
+	if err == nil {
+		err = json.NewDecoder(conn).Decode(&response)
+	}
+	switch {
+	case err != nil:
+		return secretResult{name: name, err: fmt.Errorf("failed to get secret '%s' from agent: %w", name, err)}, true
+	case response.Error != nil:
+		class := failureClass{response.Error.Class, response.Error.Code}
+		return secretResult{name: name, err: withClass(class, errors.New(response.Error.Message))}, true
+	case response.Secret == nil || response.Secret.Value == nil:
+		return secretResult{name: name, err: fmt.Errorf("secret '%s' has no value", name)}, true
+	}
+	return secretResult{name: name, secret: *response.Secret}, true
+}
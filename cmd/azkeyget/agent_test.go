@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets/fake"
+)
+
+// serveFakeVaultHTTP serves a fake vault over HTTPS on a local port and points
+// Key Vault clients at it. It returns the vault URL and a counter of the
+// credentials created.
+func serveFakeVaultHTTP(t *testing.T, vault *fakeVault) (string, *int) {
+	t.Helper()
+
+	transport := fake.NewServerTransport(vault.server())
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Scheme, r.URL.Host, r.RequestURI = "https", r.Host, ""
+		r = r.WithContext(context.WithValue(r.Context(), runtime.CtxAPINameKey{}, r.Header.Get(fakeAPINameHeader)))
+		resp, err := transport.Do(r)
+		// The fake reports error responses as errors, having consumed their body
+		var respErr *azcore.ResponseError
+		if errors.As(err, &respErr) {
+			body, _ := json.Marshal(map[string]any{"error": map[string]string{"code": respErr.ErrorCode, "message": respErr.Error()}})
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(respErr.StatusCode)
+			_, _ = w.Write(body)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		maps.Copy(w.Header(), resp.Header)
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(server.Close)
+
+	previousCredential, previousOptions := newCredential, clientOptions
+	t.Cleanup(func() {
+		newCredential, clientOptions = previousCredential, previousOptions
+	})
+	credentials := 0
+	newCredential = func(authSettings) (azcore.TokenCredential, error) {
+		credentials++
+		return &azfake.TokenCredential{}, nil
+	}
+	clientOptions = &azsecrets.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Transport: apiNameTransport{server.Client()},
+			Retry:     policy.RetryOptions{MaxRetries: -1},
+		},
+	}
+	return server.URL + "/", &credentials
+}
+
+// fakeAPINameHeader carries the client method across HTTP, as the fake server
+// dispatches on it.
+const fakeAPINameHeader = "X-Fake-Api-Name"
+
+// apiNameTransport sends the client method of each request in a header.
+type apiNameTransport struct {
+	client *http.Client
+}
+
+func (t apiNameTransport) Do(req *http.Request) (*http.Response, error) {
+	if name, ok := req.Context().Value(runtime.CtxAPINameKey{}).(string); ok {
+		req.Header.Set(fakeAPINameHeader, name)
+	}
+	return t.client.Do(req)
+}
+
+// startAgent runs an agent on a socket in a temporary directory until the
+// test ends and returns the socket.
+func startAgent(t *testing.T, ttl time.Duration) string {
+	t.Helper()
+
+	// Socket paths are limited to about 100 bytes
+	dir, err := os.MkdirTemp("", "azkeyget-agent")
+	if err != nil {
+		t.Fatalf("Failed to create socket directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "agent.sock")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ready := readyWriter(make(chan struct{}))
+	done := make(chan error, 1)
+	go func() { done <- serveAgent(ctx, ready, socket, ttl) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("serveAgent() unexpected error: %v", err)
+		}
+	})
+
+	select {
+	case <-ready:
+	case err := <-done:
+		t.Fatalf("serveAgent() stopped: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("agent did not start listening on %s", socket)
+	}
+	return socket
+}
+
+// readyWriter is closed by the first write, which the agent makes once it is
+// listening.
+type readyWriter chan struct{}
+
+func (w readyWriter) Write(p []byte) (int, error) {
+	close(w)
+	return len(p), nil
+}
+
+func TestAgent(t *testing.T) {
+	cleanTestEnvironment(t)
+	_, advance := useTestCache(t)
+	vault := newFakeVault("agent")
+	pinned := vault.add("db-password", "hunter2")
+	vault.add("api-key", "key")
+	url, credentials := serveFakeVaultHTTP(t, vault)
+	socket := startAgent(t, 5*time.Minute)
+	t.Setenv(agentSocketEnv, socket)
+
+	if info, err := os.Stat(socket); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("agent socket mode = %v, %v; want 0600", info, err)
+	}
+	if *credentials != 1 {
+		t.Fatalf("agent created %d credentials; want 1", *credentials)
+	}
+	// Invocations must not authenticate themselves while the agent serves them
+	newCredential = func(authSettings) (azcore.TokenCredential, error) {
+		return nil, errors.New("invocation tried to authenticate")
+	}
+
+	steps := []struct {
+		name     string
+		before   func()
+		args     []string
+		expected string
+		gets     int // GetSecret calls served by the vault so far
+		code     int // exit code of the expected failure, 0 for success
+	}{
+		{name: "first lookup", args: []string{"-s", "db-password"}, expected: "hunter2", gets: 1},
+		{name: "served from memory", args: []string{"-s", "db-password"}, expected: "hunter2", gets: 1},
+		{name: "names are case-insensitive", args: []string{"-s", "DB-PASSWORD"}, expected: "hunter2", gets: 1},
+		{name: "several secrets", args: []string{"-s", "db-password,api-key", "-o", "dotenv"}, expected: "DB_PASSWORD=hunter2\nAPI_KEY=key\n", gets: 2},
+		{name: "pinned version", args: []string{"-s", "db-password", "--version", pinned}, expected: "hunter2", gets: 3},
+		{name: "missing secret", args: []string{"-s", "missing"}, gets: 4, code: classNotFound.code},
+		{
+			name:   "rotated secret after the ttl",
+			before: func() { vault.add("db-password", "rotated"); advance(10 * time.Minute) },
+			args:   []string{"-s", "db-password"}, expected: "rotated", gets: 5,
+		},
+		{
+			name:   "disabled secret",
+			before: func() { vault.disable("api-key"); advance(10 * time.Minute) },
+			args:   []string{"-s", "api-key"}, gets: 6, code: classDisabled.code,
+		},
+	}
+
+	for _, step := range steps {
+		if step.before != nil {
+			step.before()
+		}
+		out, err := executeCommand(t, append([]string{"-v", url}, step.args...)...)
+		switch {
+		case step.code == 0 && err != nil:
+			t.Errorf("%s: unexpected error: %v", step.name, err)
+		case step.code != 0 && (err == nil || classifyError(err).Code != step.code):
+			t.Errorf("%s: error = %v; want exit code %d", step.name, err, step.code)
+		case out != step.expected:
+			t.Errorf("%s: output = %q; want %q", step.name, out, step.expected)
+		}
+		if got := vault.getCount(); got != step.gets {
+			t.Errorf("%s: vault served %d gets; want %d", step.name, got, step.gets)
+		}
+	}
+}
+
+func TestAgentFallback(t *testing.T) {
+	cleanTestEnvironment(t)
+	vault := newFakeVault("agent-fallback")
+	vault.add("db-password", "hunter2")
+	url, credentials := serveFakeVaultHTTP(t, vault)
+
+	// Without a listening agent the secret is retrieved directly
+	t.Setenv(agentSocketEnv, filepath.Join(t.TempDir(), "missing.sock"))
+	out, err := executeCommand(t, "-v", url, "-s", "db-password")
+	if err != nil {
+		t.Fatalf("executeCommand() unexpected error: %v", err)
+	}
+	if out != "hunter2" || *credentials != 1 {
+		t.Errorf("executeCommand() = %q with %d credentials; want hunter2 retrieved directly", out, *credentials)
+	}
+}
+
+func TestAgentSocket(t *testing.T) {
+	cleanTestEnvironment(t)
+	vault := newFakeVault("agent-socket")
+	serveFakeVaultHTTP(t, vault)
+	socket := startAgent(t, time.Minute)
+
+	if _, err := listenAgent(socket); err == nil || !strings.Contains(err.Error(), "already listening") {
+		t.Errorf("listenAgent() on a live socket error = %v; want already listening", err)
+	}
+
+	file := filepath.Join(t.TempDir(), "not-a-socket")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := listenAgent(file); err == nil || !strings.Contains(err.Error(), "is not a socket") {
+		t.Errorf("listenAgent() on a file error = %v; want not a socket", err)
+	}
+
+	// A malformed request is answered with a usage error
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatalf("Failed to connect to agent: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("{\"vault\": \"\"}\n")); err != nil {
+		t.Fatalf("Failed to write request: %v", err)
+	}
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if err != nil || !strings.Contains(string(buf[:n]), `"class":"usage"`) {
+		t.Errorf("agent response = %q, %v; want a usage error", buf[:n], err)
+	}
+
+	if _, err := executeCommand(t, "agent"); err == nil || !strings.Contains(err.Error(), `required flag(s) "socket" not set`) {
+		t.Errorf("agent without socket error = %v; want required socket", err)
+	}
+	if _, err := executeCommand(t, "agent", "--socket", socket, "--ttl", "soon"); err == nil || classifyError(err).Code != classUsage.code {
+		t.Errorf("agent with invalid ttl error = %v; want a usage error", err)
+	}
+}
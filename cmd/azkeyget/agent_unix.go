@@ -0,0 +1,33 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// listenUnix listens on a Unix socket that only the current user can access.
+// The socket is created with the permissions the umask leaves, so the umask
+// is narrowed while listening rather than the socket restricted afterwards.
+func listenUnix(socket string) (net.Listener, error) {
+	previous := syscall.Umask(0o177)
+	defer syscall.Umask(previous)
+	return net.Listen("unix", socket)
+}
+
+// checkAgentSocketOwner refuses an agent socket that another user created,
+// as whoever listens on it chooses the secrets that are served. A socket that
+// does not exist is left for the dial to report.
+func checkAgentSocketOwner(socket string) error {
+	info, err := os.Lstat(socket)
+	if err != nil {
+		return nil
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("agent socket '%s' is owned by another user (uid %d); refusing to use it", socket, stat.Uid)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestAgentSocketIgnoresUmask(t *testing.T) {
+	previous := syscall.Umask(0)
+	defer syscall.Umask(previous)
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := listenAgent(socket)
+	if err != nil {
+		t.Fatalf("listenAgent() unexpected error: %v", err)
+	}
+	defer listener.Close()
+	if info, err := os.Stat(socket); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("agent socket mode = %v, %v; want 0600", info, err)
+	}
+}
+
+func TestAgentSocketOwnedByOtherUser(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("changing the owner of a socket requires root")
+	}
+	cleanTestEnvironment(t)
+
+	// Socket paths are limited to about 100 bytes
+	dir, err := os.MkdirTemp("", "azkeyget-agent")
+	if err != nil {
+		t.Fatalf("Failed to create socket directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "agent.sock")
+	listener, err := listenAgent(socket)
+	if err != nil {
+		t.Fatalf("listenAgent() unexpected error: %v", err)
+	}
+	defer listener.Close()
+	if err := os.Lchown(socket, 65534, 65534); err != nil {
+		t.Fatalf("Failed to change socket owner: %v", err)
+	}
+
+	t.Setenv(agentSocketEnv, socket)
+	_, err = executeCommand(t, "-v", "https://vault.vault.azure.net/", "-s", "db-password")
+	if err == nil || !strings.Contains(err.Error(), "is owned by another user") {
+		t.Errorf("executeCommand() error = %v; want the socket refused", err)
+	}
+}
@@ -0,0 +1,632 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Archive format written by backup. The version is bumped on incompatible
+// changes to the manifest or the entry encoding.
+const (
+	backupFormat       = "azkeyget-backup"
+	backupVersion      = 1
+	backupManifestName = "manifest.json"
+
+	backupModeKeyVault = "keyvault"
+	backupModePortable = "portable"
+)
+
+// scryptParams are the key derivation parameters for new portable archives.
+// Archives record the parameters they were written with.
+var scryptParams = backupEncryption{KDF: "scrypt", N: 1 << 15, R: 8, P: 1, Cipher: "aes-256-gcm"}
+
+// Limits on the scrypt parameters read from an archive, which keep a crafted
+// manifest from making restore allocate gigabytes or run for hours. scrypt
+// needs 128*N*r bytes of memory and runs in time proportional to N*r*p.
+const (
+	maxScryptN      = 1 << 20
+	maxScryptRP     = 32
+	maxScryptMemory = 1 << 30
+)
+
+var (
+	backupFile        string
+	backupSecretNames []string
+	backupPrefix      string
+	backupTags        map[string]string
+	backupPortable    bool
+	passphraseFile    string
+	passphraseStdin   bool
+)
+
+// backupManifest describes the content of a backup archive.
+type backupManifest struct {
+	Format     string            `json:"format"`
+	Version    int               `json:"version"`
+	Mode       string            `json:"mode"`
+	Vault      string            `json:"vault"`
+	Created    time.Time         `json:"created"`
+	Encryption *backupEncryption `json:"encryption,omitempty"`
+	Secrets    []backupEntry     `json:"secrets"`
+}
+
+// backupEncryption records how the entries of a portable archive are
+// encrypted.
+type backupEncryption struct {
+	KDF    string `json:"kdf"`
+	Salt   []byte `json:"salt"`
+	N      int    `json:"n"`
+	R      int    `json:"r"`
+	P      int    `json:"p"`
+	Cipher string `json:"cipher"`
+}
+
+// backupEntry is one secret in a backup archive and the archive file holding
+// its Key Vault backup blob or encrypted value.
+type backupEntry struct {
+	Name string `json:"name"`
+	File string `json:"file"`
+}
+
+// portableSecret is the plaintext of an entry in a portable archive.
+type portableSecret struct {
+	Value       string             `json:"value"`
+	ContentType *string            `json:"contentType,omitempty"`
+	Tags        map[string]*string `json:"tags,omitempty"`
+	Enabled     *bool              `json:"enabled,omitempty"`
+	Expires     *time.Time         `json:"expires,omitempty"`
+	NotBefore   *time.Time         `json:"notBefore,omitempty"`
+}
+
+func newBackupCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backup --file <archive>",
+		Short: "Back up secrets to an archive file",
+		Long: `Back up secrets from --vault-url into a single archive file. Select secrets by
+name with --secret or by --prefix and --tag; without a selection the whole vault
+is backed up. Disabled secrets and secrets managed by a certificate are skipped.
+
+By default the archive holds the Key Vault backup blob of each secret, with all
+of its versions. Key Vault encrypts these blobs itself, and they can only be
+restored into a vault in the same subscription and geography.
+
+With --portable the archive instead holds the latest value and metadata of
+each secret, encrypted with a key derived from a passphrase (scrypt and
+AES-256-GCM). A portable archive can be restored into any vault, for example
+after moving to another subscription.`,
+		Args: usageArgs(cobra.NoArgs),
+		RunE: backupSecrets,
+	}
+
+	cmd.Flags().StringVarP(&backupFile, "file", "f", "", "Archive file to write (required)")
+	cmd.Flags().StringSliceVarP(&backupSecretNames, "secret", "s", nil, "Secret name to back up, repeatable or comma-separated")
+	cmd.Flags().StringVar(&backupPrefix, "prefix", "", "Back up secrets whose name starts with this prefix")
+	cmd.Flags().StringToStringVar(&backupTags, "tag", nil, "Back up secrets with this tag as key=value, repeatable")
+	cmd.Flags().BoolVar(&backupPortable, "portable", false, "Write values encrypted with a passphrase instead of Key Vault backup blobs")
+	addPassphraseFlags(cmd)
+
+	return cmd
+}
+
+func newRestoreCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore --file <archive>",
+		Short: "Restore secrets from an archive file",
+		Long: `Restore the secrets in an archive written by backup into --vault-url, or only
+those named with --secret.
+
+Key Vault backups are restored with all of their versions and fail for secrets
+that already exist in the vault. Portable archives need the passphrase they
+were written with, and each secret is written as a new version.`,
+		Args: usageArgs(cobra.NoArgs),
+		RunE: restoreSecrets,
+	}
+
+	cmd.Flags().StringVarP(&backupFile, "file", "f", "", "Archive file to read (required)")
+	cmd.Flags().StringSliceVarP(&backupSecretNames, "secret", "s", nil, "Secret name to restore, repeatable or comma-separated")
+	addPassphraseFlags(cmd)
+
+	return cmd
+}
+
+func addPassphraseFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&passphraseFile, "passphrase-file", getEnvOrDefault("AZKEYGET_BACKUP_PASSPHRASE_FILE", ""), "File containing the passphrase of a portable archive (env: AZKEYGET_BACKUP_PASSPHRASE_FILE)")
+	cmd.Flags().BoolVar(&passphraseStdin, "passphrase-stdin", false, "Read the passphrase of a portable archive from stdin")
+}
+
+func backupSecrets(cmd *cobra.Command, _ []string) error {
+	if err := requireFlags(cmd, "vault-url", "file"); err != nil {
+		return err
+	}
+	if len(backupSecretNames) > 0 && (backupPrefix != "" || len(backupTags) > 0) {
+		return usageErrorf("--secret cannot be combined with --prefix or --tag")
+	}
+	if err := checkPassphraseStdin(); err != nil {
+		return err
+	}
+	var key []byte
+	var encryption *backupEncryption
+	if backupPortable {
+		passphrase, err := readPassphrase()
+		if err != nil {
+			return err
+		}
+		params := scryptParams
+		params.Salt = make([]byte, 16)
+		if _, err := rand.Read(params.Salt); err != nil {
+			return fmt.Errorf("failed to generate salt: %w", err)
+		}
+		if key, err = deriveBackupKey(passphrase, &params); err != nil {
+			return err
+		}
+		encryption = &params
+	} else if passphraseFile != "" || passphraseStdin {
+		return usageErrorf("--passphrase-file and --passphrase-stdin require --portable")
+	}
+
+	setupDebugLogging()
+	mode := backupModeKeyVault
+	if backupPortable {
+		mode = backupModePortable
+	}
+	debugLog("Backing up secrets from %s to %s (mode: %s)", vaultURL, backupFile, mode)
+
+	ctx := context.Background()
+	clients := newVaultClients()
+	names := backupSecretNames
+	var skipped []string
+	if len(names) == 0 {
+		var err error
+		if names, skipped, err = selectSecrets(ctx, clients, vaultURL, listFilter{prefix: backupPrefix, tags: backupTags}); err != nil {
+			return err
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no secrets to back up in %s", vaultURL)
+	}
+	client, err := clients.client(vaultURL)
+	if err != nil {
+		return err
+	}
+
+	contents := make([][]byte, len(names))
+	errs := forEachSecret(names, func(i int, name string) error {
+		if backupPortable {
+			content, err := portableBackup(ctx, client, name, key)
+			contents[i] = content
+			return err
+		}
+		debugLog("Backing up secret '%s'", name)
+		resp, err := client.BackupSecret(ctx, name, nil)
+		if err != nil {
+			debugLog("Failed to back up secret '%s': %v", name, err)
+			return fmt.Errorf("failed to back up secret '%s': %w", name, err)
+		}
+		contents[i] = resp.Value
+		return nil
+	})
+	// An archive missing some of the selected secrets is worse than none
+	summary := &retrievalError{total: len(names), action: "back up"}
+	for _, err := range errs {
+		if err != nil {
+			reportError(os.Stderr, err)
+			summary.failed = append(summary.failed, err)
+		}
+	}
+	if len(summary.failed) > 0 {
+		return summary
+	}
+
+	manifest := backupManifest{
+		Format:     backupFormat,
+		Version:    backupVersion,
+		Mode:       mode,
+		Vault:      vaultURL,
+		Created:    time.Now().UTC().Truncate(time.Second),
+		Encryption: encryption,
+	}
+	for _, name := range names {
+		manifest.Secrets = append(manifest.Secrets, backupEntry{Name: name, File: "secrets/" + name})
+	}
+	archive, err := writeBackupArchive(manifest, contents)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(backupFile, archive, 0o600); err != nil {
+		return fmt.Errorf("failed to write '%s': %w", backupFile, err)
+	}
+
+	out := cmd.OutOrStdout()
+	for _, name := range skipped {
+		fmt.Fprintf(out, "%-9s %s\n", actionSkip, name)
+	}
+	for _, name := range names {
+		fmt.Fprintf(out, "%-9s %s\n", "backup", name)
+	}
+	fmt.Fprintf(out, "%d backed up, %d skipped (%s archive written to %s)\n", len(names), len(skipped), mode, backupFile)
+	return nil
+}
+
+// portableBackup reads the latest version of a secret and encrypts its value
+// and metadata with key.
+func portableBackup(ctx context.Context, client *azsecrets.Client, name string, key []byte) ([]byte, error) {
+	debugLog("Reading secret '%s' for a portable backup", name)
+	resp, err := client.GetSecret(ctx, name, "", nil)
+	if err != nil {
+		debugLog("Failed to get secret '%s': %v", name, err)
+		return nil, fmt.Errorf("failed to get secret '%s': %w", name, err)
+	}
+	if resp.Value == nil {
+		return nil, fmt.Errorf("secret '%s' has no value", name)
+	}
+	secret := portableSecret{Value: *resp.Value, ContentType: resp.ContentType, Tags: resp.Tags}
+	if attrs := resp.Attributes; attrs != nil {
+		secret.Enabled, secret.Expires, secret.NotBefore = attrs.Enabled, attrs.Expires, attrs.NotBefore
+	}
+	plaintext, err := json.Marshal(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode secret '%s': %w", name, err)
+	}
+	return sealBackupEntry(key, name, plaintext)
+}
+
+func restoreSecrets(cmd *cobra.Command, _ []string) error {
+	if err := requireFlags(cmd, "vault-url", "file"); err != nil {
+		return err
+	}
+	if err := checkPassphraseStdin(); err != nil {
+		return err
+	}
+	manifest, files, err := readBackupArchive(backupFile)
+	if err != nil {
+		return withClass(classUsage, err)
+	}
+
+	entries := manifest.Secrets
+	if len(backupSecretNames) > 0 {
+		entries = nil
+		for _, name := range backupSecretNames {
+			i := slices.IndexFunc(manifest.Secrets, func(entry backupEntry) bool { return entry.Name == name })
+			if i < 0 {
+				return usageErrorf("secret '%s' is not in '%s'", name, backupFile)
+			}
+			entries = append(entries, manifest.Secrets[i])
+		}
+	}
+
+	// Portable entries are all decrypted before anything is written, so a
+	// wrong passphrase restores nothing
+	var secrets []portableSecret
+	if manifest.Mode == backupModePortable {
+		if passphraseFile == "" && !passphraseStdin {
+			return usageErrorf("'%s' is a portable archive; pass its passphrase with --passphrase-file or --passphrase-stdin", backupFile)
+		}
+		passphrase, err := readPassphrase()
+		if err != nil {
+			return err
+		}
+		key, err := deriveBackupKey(passphrase, manifest.Encryption)
+		if err != nil {
+			return err
+		}
+		secrets = make([]portableSecret, len(entries))
+		for i, entry := range entries {
+			plaintext, err := openBackupEntry(key, entry.Name, files[entry.File])
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal(plaintext, &secrets[i]); err != nil {
+				return fmt.Errorf("failed to decode secret '%s': %w", entry.Name, err)
+			}
+		}
+	}
+
+	setupDebugLogging()
+	debugLog("Restoring %d secret(s) from %s (mode: %s, backed up from %s) into %s", len(entries), backupFile, manifest.Mode, manifest.Vault, vaultURL)
+
+	ctx := context.Background()
+	client, err := newVaultClients().client(vaultURL)
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name
+	}
+	errs := forEachSecret(names, func(i int, name string) error {
+		debugLog("Restoring secret '%s'", name)
+		var err error
+		if secrets != nil {
+			secret := secrets[i]
+			parameters := azsecrets.SetSecretParameters{
+				Value:       &secret.Value,
+				ContentType: secret.ContentType,
+				Tags:        secret.Tags,
+				SecretAttributes: &azsecrets.SecretAttributes{
+					Enabled:   secret.Enabled,
+					Expires:   secret.Expires,
+					NotBefore: secret.NotBefore,
+				},
+			}
+			_, err = client.SetSecret(ctx, name, parameters, nil)
+		} else {
+			_, err = client.RestoreSecret(ctx, azsecrets.RestoreSecretParameters{SecretBackup: files[entries[i].File]}, nil)
+		}
+		if err != nil {
+			debugLog("Failed to restore secret '%s': %v", name, err)
+			return fmt.Errorf("failed to restore secret '%s': %w", name, err)
+		}
+		forgetChangedSecret(vaultURL, name)
+		return nil
+	})
+
+	out := cmd.OutOrStdout()
+	summary := &retrievalError{total: len(entries), action: "restore"}
+	for i, err := range errs {
+		if err != nil {
+			reportError(os.Stderr, err)
+			summary.failed = append(summary.failed, err)
+			continue
+		}
+		fmt.Fprintf(out, "%-9s %s\n", "restore", names[i])
+	}
+	fmt.Fprintf(out, "%d restored", len(entries)-len(summary.failed))
+	if len(summary.failed) > 0 {
+		fmt.Fprintf(out, ", %d failed", len(summary.failed))
+	}
+	fmt.Fprintln(out)
+
+	if len(summary.failed) > 0 {
+		return summary
+	}
+	return nil
+}
+
+// forEachSecret calls fn for every name with at most --concurrency calls in
+// flight and returns their errors in the order of names.
+func forEachSecret(names []string, fn func(i int, name string) error) []error {
+	errs := make([]error, len(names))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range max(1, min(concurrency, len(names))) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = fn(i, names[i])
+			}
+		}()
+	}
+	for i := range names {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return errs
+}
+
+// writeBackupArchive builds a gzip-compressed tar archive holding the manifest
+// followed by the content of every entry.
+func writeBackupArchive(manifest backupManifest, contents [][]byte) ([]byte, error) {
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode backup manifest: %w", err)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	add := func(name string, data []byte) error {
+		header := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: manifest.Created, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	if err := add(backupManifestName, append(manifestJSON, '\n')); err != nil {
+		return nil, fmt.Errorf("failed to write backup archive: %w", err)
+	}
+	for i, entry := range manifest.Secrets {
+		if err := add(entry.File, contents[i]); err != nil {
+			return nil, fmt.Errorf("failed to write backup archive: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write backup archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write backup archive: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// readBackupArchive reads a backup archive and checks that its manifest is
+// supported and every entry is present.
+func readBackupArchive(path string) (*backupManifest, map[string][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read backup archive: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("'%s' is not a backup archive: %w", path, err)
+	}
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("'%s' is not a backup archive: %w", path, err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read '%s' from backup archive: %w", header.Name, err)
+		}
+		files[header.Name] = data
+	}
+
+	data, ok := files[backupManifestName]
+	if !ok {
+		return nil, nil, fmt.Errorf("'%s' is not a backup archive: no %s", path, backupManifestName)
+	}
+	var manifest backupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse backup manifest: %w", err)
+	}
+	if manifest.Format != backupFormat {
+		return nil, nil, fmt.Errorf("'%s' is not a backup archive: unknown format '%s'", path, manifest.Format)
+	}
+	if manifest.Version != backupVersion {
+		return nil, nil, fmt.Errorf("backup archive version %d is not supported (supported: %d)", manifest.Version, backupVersion)
+	}
+	switch manifest.Mode {
+	case backupModeKeyVault:
+	case backupModePortable:
+		if manifest.Encryption == nil {
+			return nil, nil, fmt.Errorf("portable backup archive '%s' has no encryption parameters", path)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported backup mode '%s'", manifest.Mode)
+	}
+	for _, entry := range manifest.Secrets {
+		if _, ok := files[entry.File]; !ok {
+			return nil, nil, fmt.Errorf("backup archive '%s' is missing secret '%s'", path, entry.Name)
+		}
+	}
+	return &manifest, files, nil
+}
+
+// deriveBackupKey derives the AES-256 key of a portable archive from the
+// passphrase.
+func deriveBackupKey(passphrase string, params *backupEncryption) ([]byte, error) {
+	if params.KDF != "scrypt" || params.Cipher != "aes-256-gcm" {
+		return nil, fmt.Errorf("unsupported backup encryption %s/%s", params.KDF, params.Cipher)
+	}
+	if params.N > maxScryptN || params.R < 1 || params.P < 1 || params.R > maxScryptRP || params.P > maxScryptRP ||
+		params.R*params.P > maxScryptRP || 128*params.N*params.R > maxScryptMemory {
+		return nil, fmt.Errorf("backup encryption parameters exceed the supported limits (N=%d, r=%d, p=%d)", params.N, params.R, params.P)
+	}
+	debugLog("Deriving backup key with scrypt (N=%d, r=%d, p=%d)", params.N, params.R, params.P)
+	key, err := scrypt.Key([]byte(passphrase), params.Salt, params.N, params.R, params.P, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive backup key: %w", err)
+	}
+	return key, nil
+}
+
+// sealBackupEntry encrypts an entry of a portable archive. The secret name is
+// authenticated with it, so entries cannot be swapped between names.
+func sealBackupEntry(key []byte, name string, plaintext []byte) ([]byte, error) {
+	return seal(key, name, plaintext)
+}
+
+// openBackupEntry decrypts an entry of a portable archive.
+func openBackupEntry(key []byte, name string, sealed []byte) ([]byte, error) {
+	plaintext, err := unseal(key, name, sealed)
+	if err != nil {
+		return nil, withClass(classUsage, fmt.Errorf("failed to decrypt secret '%s': wrong passphrase or damaged archive", name))
+	}
+	return plaintext, nil
+}
+
+// seal encrypts plaintext with AES-256-GCM under a random nonce, which is
+// prepended to the result. additional is authenticated but not stored.
+func seal(key []byte, additional string, plaintext []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, []byte(additional)), nil
+}
+
+// unseal decrypts the output of seal.
+func unseal(key []byte, additional string, sealed []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("encrypted data is truncated")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(additional))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return aead, nil
+}
+
+// checkPassphraseStdin rejects reading both the passphrase and the client
+// secret from stdin, which the passphrase would consume.
+func checkPassphraseStdin() error {
+	if passphraseStdin && clientSecretStdin {
+		return usageErrorf("stdin cannot provide both the client secret and the passphrase; use --passphrase-file or --client-secret-file")
+	}
+	return nil
+}
+
+// readPassphrase returns the passphrase of a portable archive from exactly
+// one of --passphrase-file or --passphrase-stdin.
+func readPassphrase() (string, error) {
+	var data []byte
+	switch {
+	case passphraseFile != "" && passphraseStdin:
+		return "", usageErrorf("only one of --passphrase-file and --passphrase-stdin may be given")
+	case passphraseFile != "":
+		debugLog("Reading passphrase from file: %s", passphraseFile)
+		if err := checkSecretFileMode(passphraseFile, "passphrase file"); err != nil {
+			return "", err
+		}
+		var err error
+		if data, err = os.ReadFile(passphraseFile); err != nil {
+			return "", fmt.Errorf("failed to read passphrase file: %w", err)
+		}
+	case passphraseStdin:
+		debugLog("Reading passphrase from stdin")
+		var err error
+		if data, err = io.ReadAll(stdin); err != nil {
+			return "", fmt.Errorf("failed to read passphrase from stdin: %w", err)
+		}
+	default:
+		return "", usageErrorf("a portable archive needs a passphrase; pass --passphrase-file or --passphrase-stdin")
+	}
+	passphrase := trimTrailingNewline(string(data))
+	if passphrase == "" {
+		return "", usageErrorf("passphrase is empty")
+	}
+	return passphrase, nil
+}
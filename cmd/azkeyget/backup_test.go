@@ -0,0 +1,248 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+)
+
+// newBackupVault returns a vault with two versions of db-password, an api-key
+// with metadata and a disabled secret.
+func newBackupVault(name string) *fakeVault {
+	vault := newFakeVault(name)
+	vault.add("db-password", "old")
+	vault.add("db-password", "hunter2")
+	vault.put("api-key", azsecrets.Secret{
+		Value:       to.Ptr("key"),
+		ContentType: to.Ptr("text/plain"),
+		Tags:        map[string]*string{"team": to.Ptr("payments")},
+	})
+	vault.add("retired", "gone")
+	vault.disable("retired")
+	return vault
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	cleanTestEnvironment(t)
+	passphrase := writeImportFile(t, "passphrase", "correct horse battery staple\n")
+
+	tests := []struct {
+		name     string
+		args     []string
+		mode     string
+		versions int // versions of db-password after the restore
+	}{
+		{name: "key vault backup", mode: backupModeKeyVault, versions: 2},
+		{name: "portable backup", args: []string{"--portable", "--passphrase-file", passphrase}, mode: backupModePortable, versions: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := newBackupVault("backup-source")
+			target := newFakeVault("backup-target")
+			installFakeVaults(t, source, target)
+			archive := filepath.Join(t.TempDir(), "secrets.tar.gz")
+
+			out, err := executeCommand(t, append([]string{"-v", source.url(), "backup", "-f", archive}, tt.args...)...)
+			if err != nil {
+				t.Fatalf("backup: unexpected error: %v", err)
+			}
+			expected := "skip      retired\nbackup    api-key\nbackup    db-password\n" +
+				"2 backed up, 1 skipped (" + tt.mode + " archive written to " + archive + ")\n"
+			if out != expected {
+				t.Errorf("backup output =\n%s\nwant:\n%s", out, expected)
+			}
+
+			manifest, _, err := readBackupArchive(archive)
+			if err != nil {
+				t.Fatalf("readBackupArchive() unexpected error: %v", err)
+			}
+			if manifest.Mode != tt.mode || manifest.Vault != source.url() || len(manifest.Secrets) != 2 {
+				t.Errorf("manifest = %+v; want %s mode with 2 secrets from %s", manifest, tt.mode, source.url())
+			}
+
+			args := []string{"-v", target.url(), "restore", "--file", archive}
+			if tt.mode == backupModePortable {
+				args = append(args, "--passphrase-file", passphrase)
+			}
+			out, err = executeCommand(t, args...)
+			if err != nil {
+				t.Fatalf("restore: unexpected error: %v", err)
+			}
+			if want := "restore   api-key\nrestore   db-password\n2 restored\n"; out != want {
+				t.Errorf("restore output =\n%s\nwant:\n%s", out, want)
+			}
+
+			secret, ok := target.latest("db-password")
+			if !ok || *secret.Value != "hunter2" {
+				t.Errorf("restored db-password = %v; want hunter2", secret.Value)
+			}
+			if got := len(target.secrets["db-password"]); got != tt.versions {
+				t.Errorf("restored db-password has %d versions; want %d", got, tt.versions)
+			}
+			secret, _ = target.latest("api-key")
+			if secret.ContentType == nil || *secret.ContentType != "text/plain" || secret.Tags["team"] == nil || *secret.Tags["team"] != "payments" {
+				t.Errorf("restored api-key lost its metadata: %+v", secret)
+			}
+			if _, ok := target.latest("retired"); ok {
+				t.Errorf("disabled secret was restored")
+			}
+		})
+	}
+}
+
+func TestBackupSelection(t *testing.T) {
+	cleanTestEnvironment(t)
+	vault := newBackupVault("backup-selection")
+	vault.add("db-user", "admin")
+	installFakeVaults(t, vault)
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"by name", []string{"--secret", "api-key"}, []string{"api-key"}},
+		{"by prefix", []string{"--prefix", "db-"}, []string{"db-password", "db-user"}},
+		{"by tag", []string{"--tag", "team=payments"}, []string{"api-key"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := filepath.Join(t.TempDir(), "backup.tar.gz")
+			if _, err := executeCommand(t, append([]string{"-v", vault.url(), "backup", "-f", archive}, tt.args...)...); err != nil {
+				t.Fatalf("executeCommand() unexpected error: %v", err)
+			}
+			manifest, files, err := readBackupArchive(archive)
+			if err != nil {
+				t.Fatalf("readBackupArchive() unexpected error: %v", err)
+			}
+			var names []string
+			for _, entry := range manifest.Secrets {
+				names = append(names, entry.Name)
+				if len(files[entry.File]) == 0 {
+					t.Errorf("archive entry for '%s' is empty", entry.Name)
+				}
+			}
+			if strings.Join(names, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("archive holds %v; want %v", names, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRestoreErrors(t *testing.T) {
+	cleanTestEnvironment(t)
+	source := newBackupVault("restore-source")
+	target := newFakeVault("restore-target")
+	target.add("api-key", "existing")
+	installFakeVaults(t, source, target)
+
+	dir := t.TempDir()
+	passphrase := writeImportFile(t, "passphrase", "right")
+	wrongPassphrase := writeImportFile(t, "wrong", "wrong")
+	keyVaultArchive := filepath.Join(dir, "keyvault.tar.gz")
+	portableArchive := filepath.Join(dir, "portable.tar.gz")
+	if _, err := executeCommand(t, "-v", source.url(), "backup", "-f", keyVaultArchive); err != nil {
+		t.Fatalf("backup: unexpected error: %v", err)
+	}
+	if _, err := executeCommand(t, "-v", source.url(), "backup", "-f", portableArchive, "--portable", "--passphrase-file", passphrase); err != nil {
+		t.Fatalf("backup: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		args          []string
+		errorContains string
+		usage         bool
+	}{
+		{"wrong passphrase", []string{"-f", portableArchive, "--passphrase-file", wrongPassphrase}, "wrong passphrase or damaged archive", true},
+		{"missing passphrase", []string{"-f", portableArchive}, "is a portable archive", true},
+		{"not an archive", []string{"-f", passphrase}, "is not a backup archive", true},
+		{"unknown secret", []string{"-f", keyVaultArchive, "-s", "missing"}, "secret 'missing' is not in", true},
+		{"existing secret", []string{"-f", keyVaultArchive}, "failed to restore 1 of 2 secrets", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := executeCommand(t, append([]string{"-v", target.url(), "restore"}, tt.args...)...)
+			if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+				t.Errorf("executeCommand() error = %v; should contain %s", err, tt.errorContains)
+			}
+			if err != nil && (classifyError(err).Code == classUsage.code) != tt.usage {
+				t.Errorf("executeCommand() error %v: usage error = %t; want %t", err, !tt.usage, tt.usage)
+			}
+		})
+	}
+
+	// Only the secret that did not exist was restored
+	if secret, _ := target.latest("api-key"); *secret.Value != "existing" {
+		t.Errorf("api-key = %q; want the existing value", *secret.Value)
+	}
+	if _, ok := target.latest("db-password"); !ok {
+		t.Errorf("db-password was not restored")
+	}
+}
+
+func TestBackupUsageErrors(t *testing.T) {
+	cleanTestEnvironment(t)
+	passphrase := writeImportFile(t, "passphrase", "secret")
+	emptyPassphrase := writeImportFile(t, "empty", "\n")
+
+	tests := []struct {
+		name          string
+		args          []string
+		errorContains string
+	}{
+		{"no file", []string{"-v", "vault", "backup"}, `required flag(s) "file" not set`},
+		{"name and prefix", []string{"-v", "vault", "backup", "-f", "out", "-s", "a", "--prefix", "b"}, "--secret cannot be combined with --prefix or --tag"},
+		{"passphrase without portable", []string{"-v", "vault", "backup", "-f", "out", "--passphrase-file", passphrase}, "require --portable"},
+		{"portable without passphrase", []string{"-v", "vault", "backup", "-f", "out", "--portable"}, "needs a passphrase"},
+		{"empty passphrase", []string{"-v", "vault", "backup", "-f", "out", "--portable", "--passphrase-file", emptyPassphrase}, "passphrase is empty"},
+		{"restore without file", []string{"-v", "vault", "restore"}, `required flag(s) "file" not set`},
+		{"both from stdin", []string{"-v", "vault", "backup", "-f", "out", "--portable", "--passphrase-stdin", "--client-secret-stdin"}, "stdin cannot provide both"},
+		{"restore both from stdin", []string{"-v", "vault", "restore", "-f", "in", "--passphrase-stdin", "--client-secret-stdin"}, "stdin cannot provide both"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := executeCommand(t, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+				t.Errorf("executeCommand() error = %v; should contain %s", err, tt.errorContains)
+			}
+			if err != nil && classifyError(err).Code != classUsage.code {
+				t.Errorf("executeCommand() error %v is not a usage error", err)
+			}
+		})
+	}
+}
+
+func TestDeriveBackupKeyLimits(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	tests := []struct {
+		name    string
+		n, r, p int
+	}{
+		{"large N", 1 << 30, 8, 1},
+		{"large r", 1 << 15, 1 << 20, 1},
+		{"large p", 1 << 15, 8, 1 << 30},
+		{"large r*p", 1 << 15, 8, 8},
+		{"large memory", 1 << 20, 16, 1},
+		{"overflowing r*p", 1 << 15, 1 << 30, 1 << 30},
+		{"zero r", 1 << 15, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := scryptParams
+			params.Salt, params.N, params.R, params.P = salt, tt.n, tt.r, tt.p
+			_, err := deriveBackupKey("passphrase", &params)
+			if err == nil || !strings.Contains(err.Error(), "exceed the supported limits") {
+				t.Errorf("deriveBackupKey(N=%d, r=%d, p=%d) error = %v; want a limits error", tt.n, tt.r, tt.p, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cacheTTL string
+	noCache  bool
+)
+
+// cacheClock returns the current time for cache expiry. Tests replace it.
+var cacheClock = time.Now
+
+// cacheKeyFile is the name of the file holding the random part of the cache
+// key, inside the cache directory.
+const cacheKeyFile = "key"
+
+// secretCache is an on-disk cache of retrieved secrets. Each entry is a file
+// in a directory per secret, so that every version of a secret can be dropped
+// at once, and is encrypted with a key derived from a random per-user key
+// file and the machine ID. File names are hashes, so the cache does not reveal
+// which secrets were read.
+type secretCache struct {
+	dir       string
+	ttl       time.Duration
+	key       []byte
+	principal string
+}
+
+// cacheEntry is the plaintext of a cache file.
+type cacheEntry struct {
+	Stored time.Time        `json:"stored"`
+	Secret azsecrets.Secret `json:"secret"`
+}
+
+func newCacheCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the local secret cache",
+		Long: `Manage the on-disk cache of retrieved secrets enabled by --cache-ttl. The cache
+is kept in $AZKEYGET_CACHE_DIR, or azkeyget in the user cache directory.`,
+		Args: usageArgs(cobra.NoArgs),
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "clear",
+		Short: "Remove every cached secret",
+		Args:  usageArgs(cobra.NoArgs),
+		RunE:  clearCache,
+	})
+
+	return cmd
+}
+
+func clearCache(cmd *cobra.Command, _ []string) error {
+	dir, err := cacheDir()
+	if err != nil {
+		return err
+	}
+	setupDebugLogging()
+	debugLog("Clearing secret cache in %s", dir)
+
+	entries := 0
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && filepath.Dir(path) != dir {
+			entries++
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read secret cache: %w", err)
+	}
+	// The key goes too, so entries copied elsewhere cannot be read with a new one
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to clear secret cache: %w", err)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Removed %d cached secret(s)\n", entries)
+	return nil
+}
+
+// cacheDir returns the directory of the secret cache.
+func cacheDir() (string, error) {
+	if dir := os.Getenv("AZKEYGET_CACHE_DIR"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the user cache directory: %w", err)
+	}
+	return filepath.Join(dir, "azkeyget"), nil
+}
+
+// openSecretCache opens the cache in dir, creating it and its key file on
+// first use.
+func openSecretCache(dir string, ttl time.Duration) (*secretCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	secret, err := readCacheKey(filepath.Join(dir, cacheKeyFile))
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("azkeyget secret cache\x00" + machineID()))
+
+	debugLog("Using secret cache in %s (ttl %s)", dir, ttl)
+	return &secretCache{
+		dir: dir,
+		ttl: ttl,
+		key: mac.Sum(nil),
+		// Identities may differ in what they can read, so each has its own entries
+		principal: strings.Join([]string{authMethod, clientID, tenantID, userAssignedID, clientCertificate}, "\x00"),
+	}, nil
+}
+
+// readCacheKey reads the random key file of the cache, creating it if it
+// does not exist. A key file other users can read is refused.
+func readCacheKey(path string) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate cache key: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err == nil {
+		debugLog("Created cache key %s", path)
+		_, err = f.Write(key)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to write cache key: %w", err)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, fs.ErrExist) {
+		return nil, fmt.Errorf("failed to create cache key: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache key: %w", err)
+	}
+	// Windows does not report POSIX permission bits
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		return nil, fmt.Errorf("cache key '%s' is accessible by other users (mode %04o)", path, info.Mode().Perm())
+	}
+	key, err = os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("cache key '%s' is damaged; remove it with 'azkeyget cache clear'", path)
+	}
+	return key, nil
+}
+
+// machineID identifies the machine, so that a cache copied to another
+// machine cannot be decrypted there.
+func machineID() string {
+	for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		if data, err := os.ReadFile(path); err == nil {
+			return strings.TrimSpace(string(data))
+		}
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
+// get returns the cached secret for ref, unless its entry is older than the
+// TTL or the secret has expired or is not yet valid.
+func (c *secretCache) get(ref secretRef) (azsecrets.Secret, bool) {
+	path := c.path(ref)
+	sealed, err := os.ReadFile(path)
+	if err != nil {
+		return azsecrets.Secret{}, false
+	}
+	plaintext, err := c.open(path, sealed)
+	if err != nil {
+		debugLog("Ignoring unreadable cache entry for '%s': %v", ref.name, err)
+		return azsecrets.Secret{}, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(plaintext, &entry); err != nil {
+		debugLog("Ignoring unreadable cache entry for '%s': %v", ref.name, err)
+		return azsecrets.Secret{}, false
+	}
+
+	now := cacheClock()
+	if now.Sub(entry.Stored) >= c.ttl || !usable(entry.Secret, now) {
+		debugLog("Cache entry for '%s' is stale", ref.name)
+		_ = os.Remove(path)
+		return azsecrets.Secret{}, false
+	}
+	return entry.Secret, true
+}
+
+// put stores a secret retrieved for ref. Failures are only logged.
+func (c *secretCache) put(ref secretRef, secret azsecrets.Secret) {
+	now := cacheClock()
+	if !usable(secret, now) {
+		return
+	}
+	if err := c.write(c.path(ref), cacheEntry{Stored: now, Secret: secret}); err != nil {
+		debugLog("Failed to cache secret '%s': %v", ref.name, err)
+	}
+}
+
+func (c *secretCache) write(path string, entry cacheEntry) error {
+	plaintext, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	sealed, err := c.seal(path, plaintext)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, sealed, 0o600)
+}
+
+// forget drops every cached version of a secret.
+func (c *secretCache) forget(vault, name string) {
+	forgetCachedSecret(c.dir, vault, name)
+}
+
+// forgetCachedSecret drops every cached version of a secret from the cache
+// in dir, after the secret was changed, deleted or found to be unreadable.
+func forgetCachedSecret(dir, vault, name string) {
+	secretDir := filepath.Join(dir, cacheHash(normalizeVault(vault), strings.ToLower(name)))
+	if _, err := os.Stat(secretDir); err != nil {
+		return
+	}
+	debugLog("Dropping cached secret '%s'", name)
+	if err := os.RemoveAll(secretDir); err != nil {
+		debugLog("Failed to drop cached secret '%s': %v", name, err)
+	}
+}
+
+// forgetChangedSecret drops a secret that azkeyget changed from the cache,
+// whether or not caching is enabled for this invocation.
+func forgetChangedSecret(vault, name string) {
+	if dir, err := cacheDir(); err == nil {
+		forgetCachedSecret(dir, vault, name)
+	}
+}
+
+// usable reports whether a secret may be served from the cache at now: Key
+// Vault does not return disabled secrets, and expired or not yet valid
+// secrets should not be used.
+func usable(secret azsecrets.Secret, now time.Time) bool {
+	attrs := secret.Attributes
+	if attrs == nil {
+		return true
+	}
+	switch {
+	case attrs.Enabled != nil && !*attrs.Enabled:
+		return false
+	case attrs.Expires != nil && !now.Before(*attrs.Expires):
+		return false
+	case attrs.NotBefore != nil && now.Before(*attrs.NotBefore):
+		return false
+	}
+	return true
+}
+
+// path returns the cache file of ref. Secret names are case-insensitive.
+func (c *secretCache) path(ref secretRef) string {
+	return filepath.Join(c.dir,
+		cacheHash(normalizeVault(ref.vault), strings.ToLower(ref.name)),
+		cacheHash(c.principal, ref.version))
+}
+
+func (c *secretCache) seal(path string, plaintext []byte) ([]byte, error) {
+	// The path is authenticated, so entries cannot be swapped between secrets
+	return seal(c.key, c.relative(path), plaintext)
+}
+
+func (c *secretCache) open(path string, sealed []byte) ([]byte, error) {
+	return unseal(c.key, c.relative(path), sealed)
+}
+
+func (c *secretCache) relative(path string) string {
+	rel, _ := filepath.Rel(c.dir, path)
+	return filepath.ToSlash(rel)
+}
+
+func normalizeVault(vault string) string {
+	return strings.TrimSuffix(strings.ToLower(vault), "/")
+}
+
+// cacheHash hashes the parts of a cache key into a file name.
+func cacheHash(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+)
+
+// useTestCache points the secret cache at a temporary directory and lets the
+// test move the cache clock forward. It returns the cache directory and a
+// function advancing the clock.
+func useTestCache(t *testing.T) (string, func(time.Duration)) {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "cache")
+	t.Setenv("AZKEYGET_CACHE_DIR", dir)
+
+	now := time.Now()
+	previous := cacheClock
+	t.Cleanup(func() { cacheClock = previous })
+	cacheClock = func() time.Time { return now }
+	return dir, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestSecretCache(t *testing.T) {
+	cleanTestEnvironment(t)
+	_, advance := useTestCache(t)
+	vault := newFakeVault("cache")
+	pinned := vault.add("db-password", "hunter2")
+	installFakeVaults(t, vault)
+
+	get := []string{"-v", vault.url(), "-s", "db-password", "--cache-ttl", "1h"}
+	steps := []struct {
+		name     string
+		before   func()
+		args     []string
+		expected string // empty for output that is not checked
+		gets     int    // GetSecret calls served by the vault so far
+	}{
+		{name: "first retrieval fills the cache", args: get, expected: "hunter2", gets: 1},
+		{name: "second retrieval is cached", args: get, expected: "hunter2", gets: 1},
+		{name: "no cache bypasses it", args: append(get, "--no-cache"), expected: "hunter2", gets: 2},
+		{name: "caching is opt-in", args: []string{"-v", vault.url(), "-s", "db-password"}, expected: "hunter2", gets: 3},
+		{
+			name:     "rotation outside azkeyget is seen after the ttl",
+			before:   func() { vault.add("db-password", "rotated"); advance(30 * time.Minute) },
+			args:     get,
+			expected: "hunter2",
+			gets:     3,
+		},
+		{name: "expired entry is refreshed", before: func() { advance(31 * time.Minute) }, args: get, expected: "rotated", gets: 4},
+		{name: "set drops the cached secret", args: []string{"-v", vault.url(), "set", "--secret", "db-password", "--value", "changed"}, gets: 4},
+		{name: "set value is retrieved", args: get, expected: "changed", gets: 5},
+		{name: "versions are cached separately", args: append(get, "--version", pinned), expected: "hunter2", gets: 6},
+		{name: "cached version", args: append(get, "--version", pinned), expected: "hunter2", gets: 6},
+	}
+
+	for _, step := range steps {
+		if step.before != nil {
+			step.before()
+		}
+		out, err := executeCommand(t, step.args...)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", step.name, err)
+		}
+		if step.expected != "" && out != step.expected {
+			t.Errorf("%s: output = %q; want %q", step.name, out, step.expected)
+		}
+		if got := vault.getCount(); got != step.gets {
+			t.Errorf("%s: vault served %d gets; want %d", step.name, got, step.gets)
+		}
+	}
+}
+
+func TestSecretCacheDropsWrittenSecrets(t *testing.T) {
+	cleanTestEnvironment(t)
+	useTestCache(t)
+	vault := newFakeVault("cache-written")
+	vault.add("db-password", "hunter2")
+	installFakeVaults(t, vault)
+
+	get := func() string {
+		t.Helper()
+		out, err := executeCommand(t, "-v", vault.url(), "-s", "db-password", "--cache-ttl", "1h")
+		if err != nil {
+			t.Fatalf("get: unexpected error: %v", err)
+		}
+		return out
+	}
+	if out := get(); out != "hunter2" {
+		t.Fatalf("get = %q; want hunter2", out)
+	}
+
+	// Secrets written by import are retrieved again, well within the ttl
+	file := filepath.Join(t.TempDir(), "secrets.env")
+	if err := os.WriteFile(file, []byte("DB_PASSWORD=rotated\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write import file: %v", err)
+	}
+	if _, err := executeCommand(t, "-v", vault.url(), "import", "--file", file); err != nil {
+		t.Fatalf("import: unexpected error: %v", err)
+	}
+	if out := get(); out != "rotated" {
+		t.Errorf("get after import = %q; want rotated", out)
+	}
+}
+
+func TestSecretCacheDropsUnusableSecrets(t *testing.T) {
+	cleanTestEnvironment(t)
+	dir, advance := useTestCache(t)
+	vault := newFakeVault("cache-unusable")
+	vault.add("disabled-later", "value")
+	vault.put("expiring", azsecrets.Secret{
+		Value:      to.Ptr("soon"),
+		Attributes: &azsecrets.SecretAttributes{Expires: to.Ptr(cacheClock().Add(10 * time.Minute))},
+	})
+	installFakeVaults(t, vault)
+
+	get := func(name string) error {
+		_, err := executeCommand(t, "-v", vault.url(), "-s", name, "--cache-ttl", "1h")
+		return err
+	}
+	for _, name := range []string{"disabled-later", "expiring"} {
+		if err := get(name); err != nil {
+			t.Fatalf("get %s: unexpected error: %v", name, err)
+		}
+	}
+	if entries := cacheEntries(t, dir); len(entries) != 2 {
+		t.Fatalf("cache holds %d entries; want 2", len(entries))
+	}
+
+	// A secret past its expiry is fetched again, even within the ttl
+	advance(15 * time.Minute)
+	if err := get("expiring"); err != nil {
+		t.Fatalf("get expiring: unexpected error: %v", err)
+	}
+	if got := vault.getCount(); got != 3 {
+		t.Errorf("vault served %d gets; want the expired secret fetched again", got)
+	}
+
+	// A secret found disabled is dropped from the cache
+	vault.disable("disabled-later")
+	advance(time.Hour)
+	err := get("disabled-later")
+	if err == nil || classifyError(err).Code != classDisabled.code {
+		t.Errorf("get disabled-later error = %v; want a disabled error", err)
+	}
+	if entries := cacheEntries(t, dir); len(entries) != 0 {
+		t.Errorf("cache still holds %v", entries)
+	}
+}
+
+func TestSecretCacheFiles(t *testing.T) {
+	cleanTestEnvironment(t)
+	dir, _ := useTestCache(t)
+	vault := newFakeVault("cache-files")
+	vault.add("db-password", "hunter2")
+	installFakeVaults(t, vault)
+
+	if _, err := executeCommand(t, "-v", vault.url(), "-s", "db-password", "--cache-ttl", "1h"); err != nil {
+		t.Fatalf("executeCommand() unexpected error: %v", err)
+	}
+	entries := cacheEntries(t, dir)
+	if len(entries) != 1 {
+		t.Fatalf("cache holds %d entries; want 1", len(entries))
+	}
+	for _, path := range entries {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read cache entry: %v", err)
+		}
+		if bytes.Contains(data, []byte("hunter2")) || strings.Contains(path, "db-password") {
+			t.Errorf("cache entry %s reveals the secret", path)
+		}
+		if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+			t.Errorf("cache entry mode = %04o; want 0600", info.Mode().Perm())
+		}
+	}
+
+	// A cache whose key other users can read is not used
+	if err := os.Chmod(filepath.Join(dir, cacheKeyFile), 0o644); err != nil {
+		t.Fatalf("Failed to chmod cache key: %v", err)
+	}
+	if _, err := executeCommand(t, "-v", vault.url(), "-s", "db-password", "--cache-ttl", "1h"); err != nil {
+		t.Fatalf("executeCommand() unexpected error: %v", err)
+	}
+	if got := vault.getCount(); got != 2 {
+		t.Errorf("vault served %d gets; want the insecure cache skipped", got)
+	}
+
+	out, err := executeCommand(t, "cache", "clear")
+	if err != nil {
+		t.Fatalf("cache clear: unexpected error: %v", err)
+	}
+	if out != "Removed 1 cached secret(s)\n" {
+		t.Errorf("cache clear output = %q", out)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("cache directory still exists after cache clear: %v", err)
+	}
+}
+
+func TestCacheTTLErrors(t *testing.T) {
+	cleanTestEnvironment(t)
+	useTestCache(t)
+
+	for _, ttl := range []string{"soon", "-1h"} {
+		_, err := executeCommand(t, "-v", "https://vault.vault.azure.net/", "-s", "x", "--cache-ttl", ttl)
+		if err == nil || !strings.Contains(err.Error(), "invalid --cache-ttl") {
+			t.Errorf("--cache-ttl %s: error = %v; want invalid --cache-ttl", ttl, err)
+		}
+		if err != nil && classifyError(err).Code != classUsage.code {
+			t.Errorf("--cache-ttl %s: error %v is not a usage error", ttl, err)
+		}
+	}
+}
+
+// cacheEntries returns the paths of the entries in a cache directory.
+func cacheEntries(t *testing.T, dir string) []string {
+	t.Helper()
+
+	var entries []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && filepath.Dir(path) != dir {
+			entries = append(entries, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to read cache directory: %v", err)
+	}
+	return entries
+}
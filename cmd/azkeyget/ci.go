@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	ciSystem string
+	ciFile   string
+)
+
+// ciSystems lists the values accepted by --ci.
+var ciSystems = []string{"azure-devops", "gitlab"}
+
+// azureDevOpsEscaper escapes the properties and data of an Azure DevOps
+// logging command, which the agent decodes again. Percent signs are left as
+// they are, as agents only decode them when DECODE_PERCENTS is set.
+var azureDevOpsEscaper = strings.NewReplacer("\r", "%0D", "\n", "%0A", ";", "%3B", "]", "%5D")
+
+// azureDevOpsEscapes are the sequences the agent always decodes. As percent
+// signs cannot be escaped, a value holding one of them cannot be passed on.
+var azureDevOpsEscapes = []string{"%0D", "%0A", "%3B", "%5D"}
+
+// addCIFlags adds the flags that write secrets for a CI system.
+func addCIFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&ciSystem, "ci", getEnvOrDefault("AZKEYGET_CI", ""), "Write the secrets for a CI system instead of stdout: "+strings.Join(ciSystems, ", ")+" (env: AZKEYGET_CI)")
+	cmd.Flags().StringVar(&ciFile, "ci-file", getEnvOrDefault("AZKEYGET_CI_FILE", ""), "Dotenv report file that --ci gitlab appends to (env: AZKEYGET_CI_FILE)")
+}
+
+// validateCIFlags checks --ci and --ci-file before any secret is retrieved.
+func validateCIFlags() error {
+	switch ciSystem {
+	case "":
+		if ciFile != "" {
+			return usageErrorf("--ci-file requires --ci gitlab")
+		}
+		return nil
+	case "azure-devops":
+		if ciFile != "" {
+			return usageErrorf("--ci-file requires --ci gitlab")
+		}
+	case "gitlab":
+		if ciFile == "" {
+			return usageErrorf("--ci gitlab requires --ci-file, the file listed in artifacts:reports:dotenv")
+		}
+	default:
+		return usageErrorf("unsupported CI system: %s (supported: %s)", ciSystem, strings.Join(ciSystems, ", "))
+	}
+	if githubEnv || githubOutput {
+		return usageErrorf("--ci cannot be combined with --github-env or --github-output")
+	}
+	return nil
+}
+
+// writeCI writes every retrieved secret for the CI system selected by --ci:
+// logging commands on w for Azure DevOps, or a dotenv report file for GitLab.
+func writeCI(w io.Writer, results []secretResult) error {
+	switch ciSystem {
+	case "azure-devops":
+		return writeAzureDevOpsVariables(w, results)
+	case "gitlab":
+		return appendGitLabDotenv(ciFile, results)
+	default:
+		return validateCIFlags()
+	}
+}
+
+// writeAzureDevOpsVariables writes a task.setvariable logging command marking
+// each secret as secret. The lines of multiline values are also registered
+// with task.setsecret, so that each is masked wherever it appears in the log.
+// Values holding a sequence the agent decodes are refused before anything is
+// written, as the agent would store them altered.
+func writeAzureDevOpsVariables(w io.Writer, results []secretResult) error {
+	var outputs []secretOutput
+	for _, result := range results {
+		if result.err != nil {
+			continue
+		}
+		output := newSecretOutput(result)
+		for _, escape := range azureDevOpsEscapes {
+			if strings.Contains(output.Value, escape) {
+				return fmt.Errorf("secret '%s' holds '%s', which the Azure DevOps agent would decode; retrieve it with -o json or in a file instead", output.Name, escape)
+			}
+		}
+		outputs = append(outputs, output)
+	}
+
+	for _, output := range outputs {
+		if lines := strings.Split(output.Value, "\n"); len(lines) > 1 {
+			for _, line := range lines {
+				line = strings.TrimSuffix(line, "\r")
+				if strings.TrimSpace(line) != "" {
+					fmt.Fprintf(w, "##vso[task.setsecret]%s\n", azureDevOpsEscaper.Replace(line))
+				}
+			}
+		}
+		fmt.Fprintf(w, "##vso[task.setvariable variable=%s;issecret=true]%s\n",
+			azureDevOpsEscaper.Replace(output.envName()), azureDevOpsEscaper.Replace(output.Value))
+	}
+	return nil
+}
+
+// appendGitLabDotenv appends every retrieved secret to a dotenv report file
+// as NAME=value lines. GitLab takes values as they are, without unescaping,
+// and a report cannot hold line breaks, so multiline values are refused
+// before anything is written.
+func appendGitLabDotenv(path string, results []secretResult) error {
+	var buf bytes.Buffer
+	written := 0
+	for _, result := range results {
+		if result.err != nil {
+			continue
+		}
+		output := newSecretOutput(result)
+		if strings.ContainsAny(output.Value, "\r\n") {
+			return fmt.Errorf("secret '%s' holds a line break, which GitLab dotenv reports cannot hold; retrieve it with -o json or in a file instead", output.Name)
+		}
+		fmt.Fprintf(&buf, "%s=%s\n", output.envName(), output.Value)
+		written++
+	}
+
+	debugLog("Appending %d secret(s) to %s", written, path)
+	return appendFile(path, buf.Bytes())
+}
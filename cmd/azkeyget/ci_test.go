@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteAzureDevOpsVariables(t *testing.T) {
+	results := []secretResult{
+		testResult("api-key", "s3cret"),
+		testResult("conn-string", "Server=db;Password=p]w"),
+		testResult("tls-key", "-----BEGIN KEY-----\r\nabc\r\n"),
+		{name: "missing", err: os.ErrNotExist},
+	}
+
+	var out bytes.Buffer
+	if err := writeAzureDevOpsVariables(&out, results); err != nil {
+		t.Fatalf("writeAzureDevOpsVariables() unexpected error: %v", err)
+	}
+	expected := strings.Join([]string{
+		"##vso[task.setvariable variable=API_KEY;issecret=true]s3cret",
+		"##vso[task.setvariable variable=CONN_STRING;issecret=true]Server=db%3BPassword=p%5Dw",
+		"##vso[task.setsecret]-----BEGIN KEY-----",
+		"##vso[task.setsecret]abc",
+		"##vso[task.setvariable variable=TLS_KEY;issecret=true]-----BEGIN KEY-----%0D%0Aabc%0D%0A",
+	}, "\n") + "\n"
+	if out.String() != expected {
+		t.Errorf("writeAzureDevOpsVariables() =\n%s\nwant\n%s", out.String(), expected)
+	}
+}
+
+func TestWriteAzureDevOpsVariablesRefusesEscapes(t *testing.T) {
+	for _, value := range []string{"p%3Bss", "line%0Done", "line%0Atwo", "a%5Db"} {
+		var out bytes.Buffer
+		results := []secretResult{testResult("api-key", "s3cret"), testResult("odd", value)}
+		err := writeAzureDevOpsVariables(&out, results)
+		if err == nil || !strings.Contains(err.Error(), "secret 'odd' holds") {
+			t.Errorf("writeAzureDevOpsVariables(%q) error = %v; want the value refused", value, err)
+		}
+		if out.Len() != 0 {
+			t.Errorf("writeAzureDevOpsVariables(%q) wrote %q; want nothing", value, out.String())
+		}
+	}
+
+	// Other percent signs reach the agent as they are
+	var out bytes.Buffer
+	if err := writeAzureDevOpsVariables(&out, []secretResult{testResult("odd", "100%25 %3b")}); err != nil {
+		t.Errorf("writeAzureDevOpsVariables() unexpected error: %v", err)
+	}
+}
+
+func TestCIOutput(t *testing.T) {
+	cleanTestEnvironment(t)
+	vault := newFakeVault("ci")
+	vault.add("db-password", "hunter2;x")
+	vault.add("tls-key", `line one\n`+"\nline two")
+	installFakeVaults(t, vault)
+	get := []string{"-v", vault.url(), "-s", "db-password,tls-key"}
+
+	out, err := executeCommand(t, append(get, "--ci", "azure-devops")...)
+	if err != nil {
+		t.Fatalf("--ci azure-devops unexpected error: %v", err)
+	}
+	for _, line := range []string{
+		"##vso[task.setvariable variable=DB_PASSWORD;issecret=true]hunter2%3Bx",
+		`##vso[task.setvariable variable=TLS_KEY;issecret=true]line one\n%0Aline two`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("--ci azure-devops output = %q; want a line %q", out, line)
+		}
+	}
+
+	report := filepath.Join(t.TempDir(), "build.env")
+	if err := os.WriteFile(report, []byte("EXISTING=1\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write report: %v", err)
+	}
+
+	// Values reach GitLab jobs exactly as written
+	vault.add("data-dir", `C:\data\app`)
+	gitlab := []string{"-v", vault.url(), "-s", "db-password,data-dir", "--ci", "gitlab", "--ci-file", report}
+	out, err = executeCommand(t, gitlab...)
+	if err != nil {
+		t.Fatalf("--ci gitlab unexpected error: %v", err)
+	}
+	if out != "" {
+		t.Errorf("--ci gitlab stdout = %q; want the secrets in the report only", out)
+	}
+	expected := "EXISTING=1\nDB_PASSWORD=hunter2;x\nDATA_DIR=C:\\data\\app\n"
+	if data, _ := os.ReadFile(report); string(data) != expected {
+		t.Errorf("report = %q; want %q", data, expected)
+	}
+
+	// Multiline values cannot be held by a report, and nothing is written
+	_, err = executeCommand(t, append(get, "--ci", "gitlab", "--ci-file", report)...)
+	if err == nil || !strings.Contains(err.Error(), "secret 'tls-key' holds a line break") {
+		t.Errorf("--ci gitlab with a multiline value error = %v; want a line break error", err)
+	}
+	if data, _ := os.ReadFile(report); string(data) != expected {
+		t.Errorf("report after a failed write = %q; want %q", data, expected)
+	}
+}
+
+func TestCIUsageErrors(t *testing.T) {
+	cleanTestEnvironment(t)
+	t.Setenv("GITHUB_ENV", filepath.Join(t.TempDir(), "github_env"))
+
+	tests := []struct {
+		name          string
+		args          []string
+		errorContains string
+	}{
+		{name: "unknown system", args: []string{"--ci", "jenkins"}, errorContains: "unsupported CI system: jenkins"},
+		{name: "gitlab without file", args: []string{"--ci", "gitlab"}, errorContains: "--ci gitlab requires --ci-file"},
+		{name: "file without gitlab", args: []string{"--ci", "azure-devops", "--ci-file", "build.env"}, errorContains: "--ci-file requires --ci gitlab"},
+		{name: "file without ci", args: []string{"--ci-file", "build.env"}, errorContains: "--ci-file requires --ci gitlab"},
+		{name: "combined with github", args: []string{"--ci", "azure-devops", "--github-env"}, errorContains: "cannot be combined with --github-env"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-v", "https://vault.vault.azure.net/", "-s", "x"}, tt.args...)
+			_, err := executeCommand(t, args...)
+			if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+				t.Fatalf("error = %v; want it to contain %q", err, tt.errorContains)
+			}
+			if classifyError(err).Code != classUsage.code {
+				t.Errorf("error %v is not a usage error", err)
+			}
+		})
+	}
+}
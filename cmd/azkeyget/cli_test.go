@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"os"
 	"os/exec"
 	"strings"
@@ -18,6 +19,26 @@ func cleanTestEnvironment(t *testing.T) {
 		"AZURE_CLIENT_SECRET",
 		"AZURE_TENANT_ID",
 		"AZURE_USER_ASSIGNED_ID",
+		"AZURE_KEYVAULT_SECRET_VERSION",
+		"AZURE_FEDERATED_TOKEN_FILE",
+		"AZURE_AUTHORITY_HOST",
+		"AZURE_CLIENT_CERTIFICATE_PATH",
+		"AZURE_CLIENT_CERTIFICATE_PASSWORD",
+		"AZURE_CLIENT_SECRET_FILE",
+		"AZURE_KEYVAULT_ERROR_FORMAT",
+		"AZKEYGET_CONFIG",
+		"AZKEYGET_PROFILE",
+		"AZKEYGET_CACHE_TTL",
+		"AZKEYGET_CACHE_DIR",
+		"AZKEYGET_NO_CACHE",
+		"AZKEYGET_K8S_NAME",
+		"AZKEYGET_K8S_NAMESPACE",
+		"AZKEYGET_K8S_TYPE",
+		"GITHUB_ACTIONS",
+		"GITHUB_ENV",
+		"GITHUB_OUTPUT",
+		"AZKEYGET_CI",
+		"AZKEYGET_CI_FILE",
 	}
 
 	for _, envVar := range envVarsToClean {
@@ -58,6 +79,17 @@ func validateErrorOutput(t *testing.T, err error, stderr, expectedError string)
 	}
 }
 
+// validateExitCode validates the exit code of a failed command, if one is expected
+func validateExitCode(t *testing.T, err error, expected int) {
+	var exitErr *exec.ExitError
+	if expected == 0 || !errors.As(err, &exitErr) {
+		return
+	}
+	if exitErr.ExitCode() != expected {
+		t.Errorf("Exit code = %d; want %d", exitErr.ExitCode(), expected)
+	}
+}
+
 // validateSuccessOutput validates success cases
 func validateSuccessOutput(t *testing.T, err error, stderr string) {
 	if err != nil {
@@ -66,6 +98,9 @@ func validateSuccessOutput(t *testing.T, err error, stderr string) {
 }
 
 func TestCLIFlags(t *testing.T) {
+	// Keep the user's own configuration file out of the tests
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
 	// Build the binary for testing
 	buildCmd := exec.Command("go", "build", "-o", "azkeyget_test", ".")
 	if err := buildCmd.Run(); err != nil {
@@ -84,12 +119,14 @@ func TestCLIFlags(t *testing.T) {
 		envVars       map[string]string
 		expectError   bool
 		errorContains string
+		exitCode      int
 	}{
 		{
 			name:          "missing vault-url flag",
 			args:          []string{"--secret", "test-secret"},
 			expectError:   true,
 			errorContains: "required flag(s) \"vault-url\" not set",
+			exitCode:      2,
 		},
 		{
 			name:          "missing secret flag",
@@ -97,6 +134,55 @@ func TestCLIFlags(t *testing.T) {
 			expectError:   true,
 			errorContains: "required flag(s) \"secret\" not set",
 		},
+		{
+			name:          "bare secret name as argument",
+			args:          []string{"test-secret"},
+			expectError:   true,
+			errorContains: "use --secret for secret names",
+		},
+		{
+			name:          "invalid secret reference",
+			args:          []string{"https://test.vault.azure.net/keys/test-key"},
+			expectError:   true,
+			errorContains: "invalid secret URI",
+		},
+		{
+			name:          "secret flag alongside reference still needs vault-url",
+			args:          []string{"https://test.vault.azure.net/secrets/a", "--secret", "b"},
+			expectError:   true,
+			errorContains: "required flag(s) \"vault-url\" not set",
+		},
+		{
+			name:          "version flag with multiple secrets",
+			args:          []string{"--vault-url", "https://test.vault.azure.net/", "--secret", "a,b", "--version", "abc"},
+			expectError:   true,
+			errorContains: "--version can only be used with a single secret",
+		},
+		{
+			name:          "versions subcommand without secret",
+			args:          []string{"versions", "--vault-url", "https://test.vault.azure.net/"},
+			expectError:   true,
+			errorContains: "accepts 1 arg(s), received 0",
+		},
+		{
+			name:          "json error format",
+			args:          []string{"--secret", "test-secret", "--error-format", "json"},
+			expectError:   true,
+			errorContains: `{"code":2,"class":"usage","message":"required flag(s) \"vault-url\" not set"}`,
+			exitCode:      2,
+		},
+		{
+			name:          "unsupported error format",
+			args:          []string{"version", "--error-format", "xml"},
+			expectError:   true,
+			errorContains: "unsupported error format: xml",
+			exitCode:      2,
+		},
+		{
+			name:        "version subcommand",
+			args:        []string{"version"},
+			expectError: false,
+		},
 		{
 			name:        "help flag",
 			args:        []string{"--help"},
@@ -126,6 +212,7 @@ func TestCLIFlags(t *testing.T) {
 			default:
 				if tt.expectError {
 					validateErrorOutput(t, err, stderr.String(), tt.errorContains)
+					validateExitCode(t, err, tt.exitCode)
 				} else {
 					validateSuccessOutput(t, err, stderr.String())
 				}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// resolveClientSecret returns the service principal client secret of settings
+// from exactly one of --client-secret, --client-secret-file or
+// --client-secret-stdin. An empty result means no secret was given.
+func resolveClientSecret(settings authSettings) (string, error) {
+	sources := 0
+	for _, given := range []bool{settings.clientSecret != "", settings.clientSecretFile != "", settings.clientSecretStdin} {
+		if given {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return "", usageErrorf("only one of --client-secret, --client-secret-file and --client-secret-stdin may be given")
+	}
+
+	switch {
+	case settings.clientSecretFile != "":
+		debugLog("Reading client secret from file: %s", settings.clientSecretFile)
+		return readClientSecretFile(settings.clientSecretFile)
+	case settings.clientSecretStdin:
+		debugLog("Reading client secret from stdin")
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read client secret from stdin: %w", err)
+		}
+		secret := trimTrailingNewline(string(data))
+		if secret == "" {
+			return "", fmt.Errorf("client secret read from stdin is empty")
+		}
+		return secret, nil
+	default:
+		return settings.clientSecret, nil
+	}
+}
+
+// readClientSecretFile reads a client secret file, refusing files that other
+// users can read unless --allow-insecure-perms is set.
+func readClientSecretFile(path string) (string, error) {
+	if err := checkSecretFileMode(path, "client secret file"); err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read client secret file: %w", err)
+	}
+	secret := trimTrailingNewline(string(data))
+	if secret == "" {
+		return "", fmt.Errorf("client secret file '%s' is empty", path)
+	}
+	return secret, nil
+}
+
+// checkSecretFileMode refuses a file holding a secret that all users can read,
+// unless --allow-insecure-perms is set. kind describes the file in errors.
+func checkSecretFileMode(path, kind string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", kind, err)
+	}
+	// Windows does not report POSIX permission bits
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o004 != 0 && !allowInsecurePerms {
+		return fmt.Errorf("%s '%s' is readable by all users (mode %04o); restrict it with chmod 600 or pass --allow-insecure-perms", kind, path, info.Mode().Perm())
+	}
+	return nil
+}
+
+// trimTrailingNewline removes a single trailing LF or CRLF, as left by editors
+// and echo.
+func trimTrailingNewline(s string) string {
+	s = strings.TrimSuffix(s, "\n")
+	return strings.TrimSuffix(s, "\r")
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestResolveClientSecret(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string, mode os.FileMode) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatalf("Failed to chmod %s: %v", name, err)
+		}
+		return path
+	}
+	private := writeFile("private", "file-secret\n", 0o600)
+	crlf := writeFile("crlf", "file-secret\r\n", 0o600)
+	worldReadable := writeFile("world", "file-secret", 0o644)
+	empty := writeFile("empty", "\n", 0o600)
+
+	tests := []struct {
+		name          string
+		literal       string
+		file          string
+		useStdin      bool
+		stdinContent  string
+		allowInsecure bool
+		expected      string
+		errorContains string
+		unixOnly      bool
+	}{
+		{name: "literal secret", literal: "literal", expected: "literal"},
+		{name: "no secret", expected: ""},
+		{name: "file secret trims trailing newline", file: private, expected: "file-secret"},
+		{name: "file secret trims trailing CRLF", file: crlf, expected: "file-secret"},
+		{name: "stdin secret", useStdin: true, stdinContent: "stdin-secret\n", expected: "stdin-secret"},
+		{
+			name:          "literal and file",
+			literal:       "literal",
+			file:          private,
+			errorContains: "only one of --client-secret, --client-secret-file and --client-secret-stdin",
+		},
+		{
+			name:          "file and stdin",
+			file:          private,
+			useStdin:      true,
+			errorContains: "only one of --client-secret, --client-secret-file and --client-secret-stdin",
+		},
+		{
+			name:          "world-readable file",
+			file:          worldReadable,
+			errorContains: "is readable by all users",
+			unixOnly:      true,
+		},
+		{
+			name:          "world-readable file allowed",
+			file:          worldReadable,
+			allowInsecure: true,
+			expected:      "file-secret",
+		},
+		{name: "empty file", file: empty, errorContains: "is empty"},
+		{name: "missing file", file: filepath.Join(dir, "missing"), errorContains: "failed to read client secret file"},
+		{name: "empty stdin", useStdin: true, errorContains: "client secret read from stdin is empty"},
+	}
+
+	originalStdin := stdin
+	defer func() {
+		stdin = originalStdin
+		allowInsecurePerms = false
+	}()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.unixOnly && runtime.GOOS == "windows" {
+				t.Skip("file permission bits are not enforced on Windows")
+			}
+			allowInsecurePerms = tt.allowInsecure
+			stdin = strings.NewReader(tt.stdinContent)
+
+			secret, err := resolveClientSecret(authSettings{clientSecret: tt.literal, clientSecretFile: tt.file, clientSecretStdin: tt.useStdin})
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("resolveClientSecret() error = %v; should contain %s", err, tt.errorContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveClientSecret() unexpected error: %v", err)
+			}
+			if secret != tt.expected {
+				t.Errorf("resolveClientSecret() = %q; want %q", secret, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// localConfigNames are the repository-local configuration files, searched for
+// in the working directory and its parents.
+var localConfigNames = []string{".azkeyget.yaml", ".azkeyget.yml", ".azkeyget.toml"}
+
+// userConfigNames are the configuration files searched for in the user
+// configuration directory.
+var userConfigNames = []string{"config.yaml", "config.yml", "config.toml"}
+
+// config is the contents of an azkeyget configuration file.
+type config struct {
+	DefaultProfile string             `yaml:"default-profile" toml:"default-profile"`
+	Profiles       map[string]profile `yaml:"profiles" toml:"profiles"`
+}
+
+// profile is a named set of flag defaults. Keys match the flag names.
+type profile struct {
+	VaultURL string `yaml:"vault-url" toml:"vault-url"`
+	Auth     string `yaml:"auth" toml:"auth"`
+	ClientID string `yaml:"client-id" toml:"client-id"`
+	TenantID string `yaml:"tenant-id" toml:"tenant-id"`
+	Output   string `yaml:"output" toml:"output"`
+}
+
+// profileSetting is a flag default from a profile, together with the
+// environment variable that takes precedence over it.
+type profileSetting struct {
+	flag  string
+	env   string
+	value string
+}
+
+func (p profile) settings() []profileSetting {
+	return []profileSetting{
+		{"vault-url", "AZURE_KEYVAULT_URL", p.VaultURL},
+		{"auth", "AZURE_AUTH_METHOD", p.Auth},
+		{"client-id", "AZURE_CLIENT_ID", p.ClientID},
+		{"tenant-id", "AZURE_TENANT_ID", p.TenantID},
+		{"output", "AZURE_KEYVAULT_OUTPUT", p.Output},
+	}
+}
+
+// applyProfile defaults flags from the selected profile of the configuration
+// file. Precedence is flags, then environment variables, then the profile,
+// then the built-in defaults, so a profile value is only used for a flag that
+// was neither given on the command line nor set by its environment variable.
+func applyProfile(cmd *cobra.Command) error {
+	path, err := findConfigFile()
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		if profileName != "" {
+			return usageErrorf("profile '%s' selected but no configuration file was found", profileName)
+		}
+		return nil
+	}
+
+	debugLog("Loading configuration file: %s", path)
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	name := profileName
+	if name == "" {
+		name = cfg.DefaultProfile
+	}
+	if name == "" {
+		return nil
+	}
+	selected, ok := cfg.Profiles[name]
+	if !ok {
+		return usageErrorf("profile '%s' not found in %s (available: %s)", name, path, strings.Join(profileNames(cfg), ", "))
+	}
+
+	debugLog("Using profile: %s", name)
+	for _, setting := range selected.settings() {
+		if setting.value == "" {
+			continue
+		}
+		flag := cmd.Flags().Lookup(setting.flag)
+		if flag == nil || flag.Changed || os.Getenv(setting.env) != "" {
+			continue
+		}
+		debugLog("  %s: %s (from profile)", setting.flag, setting.value)
+		if err := flag.Value.Set(setting.value); err != nil {
+			return usageErrorf("invalid %s '%s' in profile '%s': %w", setting.flag, setting.value, name, err)
+		}
+	}
+	return nil
+}
+
+// findConfigFile returns the configuration file to use: --config if given,
+// otherwise the nearest repository-local file, otherwise the file in the user
+// configuration directory. An empty path means there is no configuration.
+func findConfigFile() (string, error) {
+	if configFile != "" {
+		if _, err := os.Stat(configFile); err != nil {
+			return "", usageErrorf("failed to read config file: %w", err)
+		}
+		return configFile, nil
+	}
+
+	if dir, err := os.Getwd(); err == nil {
+		for {
+			if path := firstExisting(dir, localConfigNames); path != "" {
+				return path, nil
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+
+	if dir := userConfigDir(); dir != "" {
+		return firstExisting(filepath.Join(dir, "azkeyget"), userConfigNames), nil
+	}
+	return "", nil
+}
+
+// userConfigDir returns $XDG_CONFIG_HOME, or ~/.config when it is unset.
+func userConfigDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config")
+}
+
+// firstExisting returns the first of names that is a regular file in dir.
+func firstExisting(dir string, names []string) string {
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path
+		}
+	}
+	return ""
+}
+
+// loadConfig parses a YAML or, for a .toml extension, TOML configuration
+// file. Unknown keys are rejected so that typos do not go unnoticed.
+func loadConfig(path string) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, usageErrorf("failed to read config file: %w", err)
+	}
+
+	var cfg config
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		meta, err := toml.Decode(string(data), &cfg)
+		if err == nil && len(meta.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown key '%s'", meta.Undecoded()[0])
+		}
+		if err != nil {
+			return nil, usageErrorf("failed to parse config file '%s': %w", path, err)
+		}
+		return &cfg, nil
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, usageErrorf("failed to parse config file '%s': %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// profileNames returns the names of the profiles in cfg, sorted.
+func profileNames(cfg *config) []string {
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes a configuration file named name into dir and returns its path.
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatalf("Failed to create config directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+// parseWithProfile builds the root command, parses args and applies the
+// selected profile, as happens before a command runs.
+func parseWithProfile(t *testing.T, args ...string) error {
+	t.Helper()
+
+	cmd := newRootCmd()
+	if err := cmd.ParseFlags(args); err != nil {
+		t.Fatalf("ParseFlags() unexpected error: %v", err)
+	}
+	return applyProfile(cmd)
+}
+
+func TestProfilePrecedence(t *testing.T) {
+	cleanTestEnvironment(t)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	path := writeConfig(t, t.TempDir(), "config.yaml", `
+profiles:
+  dev:
+    vault-url: https://profile.vault.azure.net/
+    auth: service-principal
+    client-id: profile-client
+    tenant-id: profile-tenant
+    output: json
+`)
+
+	settings := []struct {
+		flag     string
+		env      string
+		value    *string
+		builtin  string
+		fromEnv  string
+		fromFlag string
+		profile  string
+	}{
+		{"vault-url", "AZURE_KEYVAULT_URL", &vaultURL, "", "https://env.vault.azure.net/", "https://flag.vault.azure.net/", "https://profile.vault.azure.net/"},
+		{"auth", "AZURE_AUTH_METHOD", &authMethod, "default", "workload-identity", "system-mi", "service-principal"},
+		{"client-id", "AZURE_CLIENT_ID", &clientID, "", "env-client", "flag-client", "profile-client"},
+		{"tenant-id", "AZURE_TENANT_ID", &tenantID, "", "env-tenant", "flag-tenant", "profile-tenant"},
+		{"output", "AZURE_KEYVAULT_OUTPUT", &outputFormat, "raw", "yaml", "dotenv", "json"},
+	}
+
+	// Each case says which sources are set and which source must win
+	cases := []struct {
+		name    string
+		flag    bool
+		env     bool
+		profile bool
+		want    string
+	}{
+		{"built-in default", false, false, false, "builtin"},
+		{"profile over default", false, false, true, "profile"},
+		{"env over default", false, true, false, "env"},
+		{"env over profile", false, true, true, "env"},
+		{"flag over default", true, false, false, "flag"},
+		{"flag over profile", true, false, true, "flag"},
+		{"flag over env", true, true, false, "flag"},
+		{"flag over env and profile", true, true, true, "flag"},
+	}
+
+	for _, setting := range settings {
+		for _, tc := range cases {
+			t.Run(setting.flag+"/"+tc.name, func(t *testing.T) {
+				t.Setenv(setting.env, "")
+				if tc.env {
+					t.Setenv(setting.env, setting.fromEnv)
+				}
+				var args []string
+				if tc.profile {
+					args = append(args, "--config", path, "--profile", "dev")
+				}
+				if tc.flag {
+					args = append(args, "--"+setting.flag, setting.fromFlag)
+				}
+
+				if err := parseWithProfile(t, args...); err != nil {
+					t.Fatalf("applyProfile() unexpected error: %v", err)
+				}
+
+				want := map[string]string{
+					"builtin": setting.builtin,
+					"profile": setting.profile,
+					"env":     setting.fromEnv,
+					"flag":    setting.fromFlag,
+				}[tc.want]
+				if *setting.value != want {
+					t.Errorf("--%s = %q; want %q from %s", setting.flag, *setting.value, want, tc.want)
+				}
+			})
+		}
+	}
+}
+
+func TestFindConfigFile(t *testing.T) {
+	cleanTestEnvironment(t)
+
+	t.Run("user configuration directory", func(t *testing.T) {
+		configHome := t.TempDir()
+		t.Setenv("XDG_CONFIG_HOME", configHome)
+		t.Chdir(t.TempDir())
+		want := writeConfig(t, configHome, "azkeyget/config.yaml", "profiles: {}\n")
+
+		configFile = ""
+		got, err := findConfigFile()
+		if err != nil || got != want {
+			t.Errorf("findConfigFile() = %q, %v; want %q", got, err, want)
+		}
+	})
+
+	t.Run("repository-local file in a parent directory wins", func(t *testing.T) {
+		configHome := t.TempDir()
+		t.Setenv("XDG_CONFIG_HOME", configHome)
+		writeConfig(t, configHome, "azkeyget/config.yaml", "profiles: {}\n")
+		repo := t.TempDir()
+		want := writeConfig(t, repo, ".azkeyget.yaml", "profiles: {}\n")
+		nested := filepath.Join(repo, "services", "api")
+		if err := os.MkdirAll(nested, 0o700); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		t.Chdir(nested)
+
+		configFile = ""
+		got, err := findConfigFile()
+		if err != nil || got != want {
+			t.Errorf("findConfigFile() = %q, %v; want %q", got, err, want)
+		}
+	})
+
+	t.Run("explicit file wins", func(t *testing.T) {
+		repo := t.TempDir()
+		writeConfig(t, repo, ".azkeyget.yaml", "profiles: {}\n")
+		t.Chdir(repo)
+		want := writeConfig(t, t.TempDir(), "other.yaml", "profiles: {}\n")
+
+		configFile = want
+		t.Cleanup(func() { configFile = "" })
+		got, err := findConfigFile()
+		if err != nil || got != want {
+			t.Errorf("findConfigFile() = %q, %v; want %q", got, err, want)
+		}
+	})
+
+	t.Run("no configuration", func(t *testing.T) {
+		t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+		t.Chdir(t.TempDir())
+
+		configFile = ""
+		got, err := findConfigFile()
+		if err != nil || got != "" {
+			t.Errorf("findConfigFile() = %q, %v; want no file", got, err)
+		}
+	})
+}
+
+func TestApplyProfile(t *testing.T) {
+	cleanTestEnvironment(t)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+	dir := t.TempDir()
+
+	yamlConfig := writeConfig(t, dir, "config.yaml", `
+default-profile: staging
+profiles:
+  staging:
+    vault-url: https://staging.vault.azure.net/
+  prod:
+    vault-url: https://prod.vault.azure.net/
+`)
+	tomlConfig := writeConfig(t, dir, "config.toml", `
+[profiles.prod]
+vault-url = "https://prod.vault.azure.net/"
+output = "json"
+`)
+	unknownKey := writeConfig(t, dir, "typo.yaml", "profiles:\n  dev:\n    vault_url: https://dev.vault.azure.net/\n")
+	unknownTOMLKey := writeConfig(t, dir, "typo.toml", "[profiles.dev]\nvault_url = \"https://dev.vault.azure.net/\"\n")
+
+	tests := []struct {
+		name          string
+		args          []string
+		wantVaultURL  string
+		errorContains string
+	}{
+		{"default profile", []string{"--config", yamlConfig}, "https://staging.vault.azure.net/", ""},
+		{"selected profile", []string{"--config", yamlConfig, "--profile", "prod"}, "https://prod.vault.azure.net/", ""},
+		{"toml configuration", []string{"--config", tomlConfig, "--profile", "prod"}, "https://prod.vault.azure.net/", ""},
+		{"no profile selected", []string{"--config", tomlConfig}, "", ""},
+		{"unknown profile", []string{"--config", yamlConfig, "--profile", "qa"}, "", "profile 'qa' not found"},
+		{"profile without configuration", []string{"--profile", "prod"}, "", "no configuration file was found"},
+		{"missing configuration file", []string{"--config", filepath.Join(dir, "missing.yaml")}, "", "failed to read config file"},
+		{"unknown yaml key", []string{"--config", unknownKey}, "", "field vault_url not found"},
+		{"unknown toml key", []string{"--config", unknownTOMLKey}, "", "unknown key 'profiles.dev.vault_url'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := parseWithProfile(t, tt.args...)
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("applyProfile() error = %v; should contain %s", err, tt.errorContains)
+				}
+				if classifyError(err).Code != classUsage.code {
+					t.Errorf("applyProfile() error %v is not a usage error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyProfile() unexpected error: %v", err)
+			}
+			if vaultURL != tt.wantVaultURL {
+				t.Errorf("vault URL = %q; want %q", vaultURL, tt.wantVaultURL)
+			}
+		})
+	}
+}
+
+func TestGetSecretWithProfile(t *testing.T) {
+	cleanTestEnvironment(t)
+	vault := newFakeVault("profiled")
+	vault.add("alpha", "one")
+	installFakeVaults(t, vault)
+	path := writeConfig(t, t.TempDir(), "config.yaml", "profiles:\n  dev:\n    vault-url: "+vault.url()+"\n    output: dotenv\n")
+
+	out, err := executeCommand(t, "--config", path, "--profile", "dev", "--secret", "alpha")
+	if err != nil {
+		t.Fatalf("executeCommand() unexpected error: %v", err)
+	}
+	if out != "ALPHA=one\n" {
+		t.Errorf("executeCommand() = %q; want %q", out, "ALPHA=one\n")
+	}
+}
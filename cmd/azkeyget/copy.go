@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/spf13/cobra"
+)
+
+var (
+	copyFrom        string
+	copyTo          string
+	copySecretNames []string
+	copyPrefix      string
+	copyTags        map[string]string
+	copyAll         bool
+	copyDryRun      bool
+)
+
+// destinationAuthFlags maps the --to-* authentication flags of copy to the
+// settings they replace when authenticating to the destination vault.
+var destinationAuthFlags = []struct {
+	flag    string
+	field   func(*authSettings) *string
+	summary string
+}{
+	{"auth", func(s *authSettings) *string { return &s.method }, "Authentication method"},
+	{"client-id", func(s *authSettings) *string { return &s.clientID }, "Client ID"},
+	{"tenant-id", func(s *authSettings) *string { return &s.tenantID }, "Tenant ID"},
+	{"client-secret-file", func(s *authSettings) *string { return &s.clientSecretFile }, "File containing the client secret"},
+	{"user-assigned-id", func(s *authSettings) *string { return &s.userAssignedID }, "User-assigned managed identity client ID"},
+	{"client-certificate", func(s *authSettings) *string { return &s.clientCertificate }, "Client certificate"},
+	{"client-certificate-password", func(s *authSettings) *string { return &s.clientCertificatePassword }, "Password of the client certificate"},
+	{"federated-token-file", func(s *authSettings) *string { return &s.federatedTokenFile }, "Federated token file"},
+}
+
+// writeAction is what a command that writes secrets does, or would do, with
+// one secret.
+type writeAction string
+
+const (
+	actionCreate    writeAction = "create"
+	actionUpdate    writeAction = "update"
+	actionUnchanged writeAction = "unchanged"
+	actionSkip      writeAction = "skip"
+)
+
+func newCopyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "copy --from <vault> --to <vault>",
+		Short: "Copy secrets from one vault to another",
+		Long: `Copy the latest version of secrets from one vault to another, with their content
+type, tags, expiry and not-before time. A new version is only written when the
+value in the destination differs, so copy can be run repeatably to keep vaults
+in sync. Vaults may be given as URLs or bare vault names.
+
+Select secrets by name with --secret, by --prefix and --tag, or all with --all.
+Disabled secrets and secrets managed by a certificate are skipped.
+
+The destination vault is accessed with the credential of the source unless
+--to-auth and the other --to-* flags are given; each replaces the
+corresponding source flag for the destination only.`,
+		Args: usageArgs(cobra.NoArgs),
+		RunE: copySecrets,
+	}
+
+	cmd.Flags().StringVar(&copyFrom, "from", "", "Source vault URL or name (required)")
+	cmd.Flags().StringVar(&copyTo, "to", "", "Destination vault URL or name (required)")
+	cmd.Flags().StringSliceVarP(&copySecretNames, "secret", "s", nil, "Secret name to copy, repeatable or comma-separated")
+	cmd.Flags().StringVar(&copyPrefix, "prefix", "", "Copy secrets whose name starts with this prefix")
+	cmd.Flags().StringToStringVar(&copyTags, "tag", nil, "Copy secrets with this tag as key=value, repeatable")
+	cmd.Flags().BoolVar(&copyAll, "all", false, "Copy every secret")
+	cmd.Flags().BoolVar(&copyDryRun, "dry-run", false, "Show what would be created, updated or skipped without writing")
+	for _, auth := range destinationAuthFlags {
+		cmd.Flags().String("to-"+auth.flag, "", auth.summary+" for the destination vault")
+	}
+
+	return cmd
+}
+
+func copySecrets(cmd *cobra.Command, _ []string) error {
+	if err := requireFlags(cmd, "from", "to"); err != nil {
+		return err
+	}
+	filtered := copyPrefix != "" || len(copyTags) > 0
+	switch {
+	case len(copySecretNames) > 0 && (filtered || copyAll):
+		return usageErrorf("--secret cannot be combined with --prefix, --tag or --all")
+	case copyAll && filtered:
+		return usageErrorf("--all cannot be combined with --prefix or --tag")
+	case len(copySecretNames) == 0 && !filtered && !copyAll:
+		return usageErrorf("select the secrets to copy with --secret, --prefix, --tag or --all")
+	}
+	from, to := vaultURLFromName(copyFrom), vaultURLFromName(copyTo)
+	if strings.TrimSuffix(from, "/") == strings.TrimSuffix(to, "/") {
+		return usageErrorf("--from and --to must be different vaults")
+	}
+
+	setupDebugLogging()
+	debugLog("Copying secrets from %s to %s (dry run: %t)", from, to, copyDryRun)
+	destinationAuth, err := destinationAuthSettings(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	source := newVaultClients()
+	// Without --to-* flags both vaults share the credential of the source
+	destination := source
+	if destinationAuth != nil {
+		destination = newVaultClients()
+		destination.newCredential = func() (azcore.TokenCredential, error) {
+			return newCredential(*destinationAuth)
+		}
+	}
+
+	names := copySecretNames
+	var skipped []string
+	if len(names) == 0 {
+		if names, skipped, err = selectSecrets(ctx, source, from, listFilter{prefix: copyPrefix, tags: copyTags}); err != nil {
+			return err
+		}
+	}
+
+	sourceRefs := make([]secretRef, len(names))
+	destinationRefs := make([]secretRef, len(names))
+	for i, name := range names {
+		sourceRefs[i] = secretRef{vault: from, name: name}
+		destinationRefs[i] = secretRef{vault: to, name: name}
+	}
+	sources := fetchSecrets(ctx, source, sourceRefs, concurrency, false)
+	current := fetchSecrets(ctx, destination, destinationRefs, concurrency, false)
+
+	out := cmd.OutOrStdout()
+	for _, name := range skipped {
+		fmt.Fprintf(out, "%-9s %s\n", actionSkip, name)
+	}
+	counts := map[writeAction]int{actionSkip: len(skipped)}
+	summary := &retrievalError{total: len(names), action: "copy"}
+	for i, name := range names {
+		action, err := copySecret(ctx, destination, to, sources[i], current[i])
+		if err != nil {
+			reportError(os.Stderr, err)
+			summary.failed = append(summary.failed, err)
+			continue
+		}
+		counts[action]++
+		fmt.Fprintf(out, "%-9s %s\n", action, name)
+	}
+
+	verbs := []string{"created", "updated"}
+	if copyDryRun {
+		verbs = []string{"to create", "to update"}
+	}
+	fmt.Fprintf(out, "%d %s, %d %s, %d unchanged, %d skipped",
+		counts[actionCreate], verbs[0], counts[actionUpdate], verbs[1], counts[actionUnchanged], counts[actionSkip])
+	if copyDryRun {
+		fmt.Fprint(out, " (dry run, nothing was written)")
+	}
+	fmt.Fprintln(out)
+
+	if len(summary.failed) > 0 {
+		return summary
+	}
+	return nil
+}
+
+// copySecret brings one secret in the destination up to date with the
+// source, unless this is a dry run, and reports what it did.
+func copySecret(ctx context.Context, clients *vaultClients, vault string, source, current secretResult) (writeAction, error) {
+	if source.err != nil {
+		return "", source.err
+	}
+	action := actionCreate
+	if current.err == nil {
+		if *current.secret.Value == *source.secret.Value {
+			return actionUnchanged, nil
+		}
+		action = actionUpdate
+	} else if classifyError(current.err).Code != classNotFound.code {
+		return "", current.err
+	}
+	if copyDryRun {
+		return action, nil
+	}
+
+	client, err := clients.client(vault)
+	if err != nil {
+		return "", err
+	}
+	debugLog("Writing secret '%s' to %s", source.name, vault)
+	if _, err := client.SetSecret(ctx, source.name, copyParameters(source.secret), nil); err != nil {
+		debugLog("Failed to set secret '%s': %v", source.name, err)
+		return "", fmt.Errorf("failed to set secret '%s': %w", source.name, err)
+	}
+	forgetChangedSecret(vault, source.name)
+	return action, nil
+}
+
+// copyParameters builds a new secret version with the value and metadata of
+// secret.
+func copyParameters(secret azsecrets.Secret) azsecrets.SetSecretParameters {
+	parameters := azsecrets.SetSecretParameters{
+		Value:       secret.Value,
+		ContentType: secret.ContentType,
+		Tags:        secret.Tags,
+	}
+	if attrs := secret.Attributes; attrs != nil {
+		parameters.SecretAttributes = &azsecrets.SecretAttributes{
+			Enabled:   attrs.Enabled,
+			Expires:   attrs.Expires,
+			NotBefore: attrs.NotBefore,
+		}
+	}
+	return parameters
+}
+
+// selectSecrets lists the secrets in vault that pass filter, sorted by name.
+// Secrets that cannot be copied, because they are disabled or managed by a
+// certificate, are returned separately.
+func selectSecrets(ctx context.Context, clients *vaultClients, vault string, filter listFilter) (names, skipped []string, err error) {
+	client, err := clients.client(vault)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pager := client.NewListSecretPropertiesPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			debugLog("Failed to list secrets: %v", err)
+			return nil, nil, fmt.Errorf("failed to list secrets: %w", err)
+		}
+		for _, props := range page.Value {
+			if !filter.matches(props) {
+				continue
+			}
+			enabled := props.Attributes == nil || props.Attributes.Enabled == nil || *props.Attributes.Enabled
+			if !enabled || (props.Managed != nil && *props.Managed) {
+				debugLog("Skipping secret '%s': disabled or managed", props.ID.Name())
+				skipped = append(skipped, props.ID.Name())
+				continue
+			}
+			names = append(names, props.ID.Name())
+		}
+	}
+	debugLog("Selected %d secret(s), skipped %d", len(names), len(skipped))
+
+	sort.Strings(names)
+	sort.Strings(skipped)
+	return names, skipped, nil
+}
+
+// destinationAuthSettings returns the authentication settings of the
+// destination vault: those of the source, with the --to-* flags given to copy
+// in place of the corresponding settings. It returns nil when no --to-* flag
+// was given, as the destination is then accessed with the source credential.
+func destinationAuthSettings(cmd *cobra.Command) (*authSettings, error) {
+	settings := flagAuthSettings()
+	overridden := false
+	for _, auth := range destinationAuthFlags {
+		flag := cmd.Flags().Lookup("to-" + auth.flag)
+		if !flag.Changed {
+			continue
+		}
+		if auth.flag == "client-certificate-password" {
+			debugLog("Destination %s given", auth.flag)
+		} else {
+			debugLog("Destination %s: %s", auth.flag, flag.Value.String())
+		}
+		*auth.field(&settings) = flag.Value.String()
+		overridden = true
+	}
+	if !overridden {
+		return nil, nil
+	}
+	// A destination secret file replaces any other source of the secret
+	if cmd.Flags().Changed("to-client-secret-file") {
+		settings.clientSecret, settings.clientSecretStdin = "", false
+	}
+	// A destination certificate is not opened with the password of the source one
+	if cmd.Flags().Changed("to-client-certificate") && !cmd.Flags().Changed("to-client-certificate-password") {
+		settings.clientCertificatePassword = ""
+	}
+	// stdin can be read only once, so it cannot hold the secret of both vaults
+	if authMethod == "service-principal" && settings.method == "service-principal" && settings.clientSecretStdin {
+		return nil, usageErrorf("--client-secret-stdin cannot provide the client secret of both vaults; pass --to-client-secret-file for the destination")
+	}
+	return &settings, nil
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+)
+
+// newCopyVaults returns a source vault and a partially populated destination.
+func newCopyVaults(t *testing.T) (source, destination *fakeVault) {
+	t.Helper()
+
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	source = newFakeVault("copy-source")
+	source.put("app-db-password", azsecrets.Secret{
+		Value:       to.Ptr("hunter2"),
+		ContentType: to.Ptr("text/plain"),
+		Tags:        map[string]*string{"team": to.Ptr("payments")},
+		Attributes:  &azsecrets.SecretAttributes{Expires: &expires},
+	})
+	source.add("app-api-key", "new-key")
+	source.add("app-unchanged", "same")
+	source.add("legacy-token", "old")
+	source.disable("legacy-token")
+
+	destination = newFakeVault("copy-destination")
+	destination.add("app-api-key", "old-key")
+	destination.add("app-unchanged", "same")
+
+	installFakeVaults(t, source, destination)
+	return source, destination
+}
+
+func TestCopySecrets(t *testing.T) {
+	cleanTestEnvironment(t)
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+		values   map[string]string // destination values afterwards; "" means absent
+	}{
+		{
+			name: "all secrets",
+			args: []string{"--all"},
+			expected: "skip      legacy-token\n" +
+				"update    app-api-key\n" +
+				"create    app-db-password\n" +
+				"unchanged app-unchanged\n" +
+				"1 created, 1 updated, 1 unchanged, 1 skipped\n",
+			values: map[string]string{"app-db-password": "hunter2", "app-api-key": "new-key", "app-unchanged": "same", "legacy-token": ""},
+		},
+		{
+			name: "dry run writes nothing",
+			args: []string{"--all", "--dry-run"},
+			expected: "skip      legacy-token\n" +
+				"update    app-api-key\n" +
+				"create    app-db-password\n" +
+				"unchanged app-unchanged\n" +
+				"1 to create, 1 to update, 1 unchanged, 1 skipped (dry run, nothing was written)\n",
+			values: map[string]string{"app-db-password": "", "app-api-key": "old-key"},
+		},
+		{
+			name:     "secrets by name",
+			args:     []string{"--secret", "app-db-password"},
+			expected: "create    app-db-password\n1 created, 0 updated, 0 unchanged, 0 skipped\n",
+			values:   map[string]string{"app-db-password": "hunter2", "app-api-key": "old-key"},
+		},
+		{
+			name:     "tag filter",
+			args:     []string{"--tag", "team=payments"},
+			expected: "create    app-db-password\n1 created, 0 updated, 0 unchanged, 0 skipped\n",
+			values:   map[string]string{"app-db-password": "hunter2", "app-api-key": "old-key"},
+		},
+		{
+			name:     "prefix filter",
+			args:     []string{"--prefix", "legacy-"},
+			expected: "skip      legacy-token\n0 created, 0 updated, 0 unchanged, 1 skipped\n",
+			values:   map[string]string{"legacy-token": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, destination := newCopyVaults(t)
+			args := append([]string{"copy", "--from", source.url(), "--to", "copy-destination"}, tt.args...)
+
+			out, err := executeCommand(t, args...)
+			if err != nil {
+				t.Fatalf("executeCommand() unexpected error: %v", err)
+			}
+			if out != tt.expected {
+				t.Errorf("executeCommand() =\n%s\nwant:\n%s", out, tt.expected)
+			}
+			for name, want := range tt.values {
+				secret, ok := destination.latest(name)
+				if want == "" {
+					if ok {
+						t.Errorf("destination secret '%s' exists; want it absent", name)
+					}
+				} else if !ok || *secret.Value != want {
+					t.Errorf("destination secret '%s' = %v; want %q", name, secret.Value, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCopyPreservesMetadata(t *testing.T) {
+	cleanTestEnvironment(t)
+	source, destination := newCopyVaults(t)
+	unchanged, _ := destination.latest("app-unchanged")
+
+	if _, err := executeCommand(t, "copy", "--from", source.url(), "--to", destination.url(), "--all"); err != nil {
+		t.Fatalf("executeCommand() unexpected error: %v", err)
+	}
+
+	copied, _ := destination.latest("app-db-password")
+	original, _ := source.latest("app-db-password")
+	if *copied.ContentType != "text/plain" || *copied.Tags["team"] != "payments" || !copied.Attributes.Expires.Equal(*original.Attributes.Expires) {
+		t.Errorf("copied metadata = %v %v %v; want content type, tags and expiry of the source",
+			*copied.ContentType, copied.Tags, copied.Attributes.Expires)
+	}
+	if latest, _ := destination.latest("app-unchanged"); latest.ID.Version() != unchanged.ID.Version() {
+		t.Errorf("unchanged secret got a new version %s", latest.ID.Version())
+	}
+}
+
+func TestCopyDestinationAuth(t *testing.T) {
+	cleanTestEnvironment(t)
+	source, destination := newCopyVaults(t)
+
+	type credentialSettings struct{ auth, clientID, certificatePassword string }
+	tests := []struct {
+		name     string
+		args     []string
+		expected []credentialSettings
+	}{
+		{
+			name:     "destination shares the source credential",
+			args:     []string{"--auth", "service-principal", "--client-id", "source-client", "--client-secret-stdin"},
+			expected: []credentialSettings{{"service-principal", "source-client", ""}},
+		},
+		{
+			name: "destination flags replace source flags",
+			args: []string{"--client-id", "source-client", "--to-auth", "service-principal", "--to-client-id", "destination-client"},
+			expected: []credentialSettings{
+				{"default", "source-client", ""},
+				{"service-principal", "destination-client", ""},
+			},
+		},
+		{
+			name: "destination certificate does not use the source password",
+			args: []string{"--client-certificate", "source.pem", "--client-certificate-password", "source-password", "--to-client-certificate", "destination.pem"},
+			expected: []credentialSettings{
+				{"default", "", "source-password"},
+				{"default", "", ""},
+			},
+		},
+		{
+			name: "destination certificate password",
+			args: []string{"--client-certificate-password", "source-password", "--to-client-certificate-password", "destination-password"},
+			expected: []credentialSettings{
+				{"default", "", "source-password"},
+				{"default", "", "destination-password"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created []credentialSettings
+			newCredential = func(settings authSettings) (azcore.TokenCredential, error) {
+				created = append(created, credentialSettings{settings.method, settings.clientID, settings.clientCertificatePassword})
+				return &azfake.TokenCredential{}, nil
+			}
+
+			args := append([]string{"copy", "--from", source.url(), "--to", destination.url(), "--all", "--dry-run"}, tt.args...)
+			if _, err := executeCommand(t, args...); err != nil {
+				t.Fatalf("executeCommand() unexpected error: %v", err)
+			}
+			if !slices.Equal(created, tt.expected) {
+				t.Errorf("credentials created with %+v; want %+v", created, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCopyErrors(t *testing.T) {
+	cleanTestEnvironment(t)
+	source, destination := newCopyVaults(t)
+
+	tests := []struct {
+		name          string
+		args          []string
+		errorContains string
+		exitCode      int
+	}{
+		{"no vaults", []string{"--all"}, `required flag(s) "from", "to" not set`, classUsage.code},
+		{"no selection", []string{"--from", "a", "--to", "b"}, "select the secrets to copy", classUsage.code},
+		{"names and filters", []string{"--from", "a", "--to", "b", "--secret", "x", "--prefix", "y"}, "--secret cannot be combined", classUsage.code},
+		{"all and filters", []string{"--from", "a", "--to", "b", "--all", "--tag", "k=v"}, "--all cannot be combined", classUsage.code},
+		{"same vault", []string{"--from", "copy-source", "--to", source.url(), "--all"}, "must be different vaults", classUsage.code},
+		{
+			name:          "client secret stdin for both vaults",
+			args:          []string{"--from", source.url(), "--to", destination.url(), "--all", "--auth", "service-principal", "--client-secret-stdin", "--to-client-id", "destination-client"},
+			errorContains: "cannot provide the client secret of both vaults",
+			exitCode:      classUsage.code,
+		},
+		{
+			name:          "missing source secret",
+			args:          []string{"--from", source.url(), "--to", destination.url(), "--secret", "missing,app-api-key"},
+			errorContains: "failed to copy 1 of 2 secrets",
+			exitCode:      classNotFound.code,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := executeCommand(t, append([]string{"copy"}, tt.args...)...)
+			if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+				t.Errorf("executeCommand() error = %v; should contain %s", err, tt.errorContains)
+			}
+			if err != nil && classifyError(err).Code != tt.exitCode {
+				t.Errorf("exit code = %d; want %d", classifyError(err).Code, tt.exitCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/spf13/cobra"
+)
+
+var (
+	assumeYes   bool
+	waitDone    bool
+	deletePurge bool
+)
+
+// Polling settings for --wait. Tests shorten the interval.
+var (
+	pollInterval = 2 * time.Second
+	waitTimeout  = 5 * time.Minute
+)
+
+func newDeleteCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete <secret>",
+		Short: "Delete a secret",
+		Long: `Delete a secret and all of its versions. In a vault with soft-delete enabled the
+secret can be restored with recover until it is purged; --purge deletes it
+permanently. The secret may be a name in --vault-url or a secret reference.`,
+		Args: usageArgs(cobra.ExactArgs(1)),
+		RunE: deleteSecret,
+	}
+
+	cmd.Flags().BoolVar(&waitDone, "wait", false, "Wait until the deletion has completed")
+	cmd.Flags().BoolVar(&deletePurge, "purge", false, "Purge the secret once it is deleted, so that it cannot be recovered")
+	cmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation")
+
+	return cmd
+}
+
+func newRecoverCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "recover <secret>",
+		Short: "Recover a deleted secret",
+		Long:  "Restore a soft-deleted secret with all of its versions. The secret may be a name in --vault-url or a secret reference.",
+		Args:  usageArgs(cobra.ExactArgs(1)),
+		RunE:  recoverSecret,
+	}
+
+	cmd.Flags().BoolVar(&waitDone, "wait", false, "Wait until the secret can be read again")
+
+	return cmd
+}
+
+func newPurgeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "purge <secret>",
+		Short: "Permanently delete a deleted secret",
+		Long:  "Permanently delete a soft-deleted secret. This cannot be undone. The secret may be a name in --vault-url or a secret reference.",
+		Args:  usageArgs(cobra.ExactArgs(1)),
+		RunE:  purgeSecret,
+	}
+
+	cmd.Flags().BoolVar(&waitDone, "wait", false, "Wait until the purge has completed")
+	cmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation")
+
+	return cmd
+}
+
+func newListDeletedCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list-deleted",
+		Short: "List deleted secrets",
+		Long:  "List the soft-deleted secrets in --vault-url with their deletion and scheduled purge dates.",
+		Args:  usageArgs(cobra.NoArgs),
+		RunE:  listDeletedSecrets,
+	}
+}
+
+func deleteSecret(cmd *cobra.Command, args []string) error {
+	ref, err := writableSecretRef(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	action := "Delete"
+	if deletePurge {
+		action = "Permanently delete"
+	}
+	if err := confirm(cmd, fmt.Sprintf("%s secret '%s' from %s?", action, ref.name, ref.vault)); err != nil {
+		return err
+	}
+
+	setupDebugLogging()
+	client, err := newKeyVaultClient(ref.vault)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	debugLog("Deleting secret '%s' in %s", ref.name, ref.vault)
+	response, err := client.DeleteSecret(ctx, ref.name, nil)
+	if err != nil {
+		debugLog("Failed to delete secret '%s': %v", ref.name, err)
+		return fmt.Errorf("failed to delete secret '%s': %w", ref.name, err)
+	}
+	forgetChangedSecret(ref.vault, ref.name)
+
+	// Without soft-delete there is no recovery ID and the deletion is final
+	if response.RecoveryID == nil {
+		fmt.Fprintf(cmd.OutOrStdout(), "Deleted secret '%s' permanently (soft-delete is not enabled)\n", ref.name)
+		return nil
+	}
+
+	// A secret can only be purged once its deletion has completed
+	if waitDone || deletePurge {
+		err := waitFor(ctx, fmt.Sprintf("deletion of secret '%s'", ref.name), func(ctx context.Context) (bool, error) {
+			_, err := client.GetDeletedSecret(ctx, ref.name, nil)
+			return found(err)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if deletePurge {
+		return purge(ctx, cmd, client, ref.name)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Deleted secret '%s'; recoverable until %s\n", ref.name, formatDate(response.ScheduledPurgeDate))
+	return nil
+}
+
+func recoverSecret(cmd *cobra.Command, args []string) error {
+	ref, err := writableSecretRef(cmd, args[0])
+	if err != nil {
+		return err
+	}
+
+	setupDebugLogging()
+	client, err := newKeyVaultClient(ref.vault)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	debugLog("Recovering secret '%s' in %s", ref.name, ref.vault)
+	if _, err := client.RecoverDeletedSecret(ctx, ref.name, nil); err != nil {
+		debugLog("Failed to recover secret '%s': %v", ref.name, err)
+		return fmt.Errorf("failed to recover secret '%s': %w", ref.name, err)
+	}
+	forgetChangedSecret(ref.vault, ref.name)
+	if waitDone {
+		err := waitFor(ctx, fmt.Sprintf("recovery of secret '%s'", ref.name), func(ctx context.Context) (bool, error) {
+			_, err := client.GetSecret(ctx, ref.name, "", nil)
+			return found(err)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Recovered secret '%s'\n", ref.name)
+	return nil
+}
+
+func purgeSecret(cmd *cobra.Command, args []string) error {
+	ref, err := writableSecretRef(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	if err := confirm(cmd, fmt.Sprintf("Permanently purge deleted secret '%s' from %s? This cannot be undone.", ref.name, ref.vault)); err != nil {
+		return err
+	}
+
+	setupDebugLogging()
+	client, err := newKeyVaultClient(ref.vault)
+	if err != nil {
+		return err
+	}
+	return purge(context.Background(), cmd, client, ref.name)
+}
+
+// purge permanently deletes a deleted secret, waiting for the purge to
+// complete when --wait is set.
+func purge(ctx context.Context, cmd *cobra.Command, client *azsecrets.Client, name string) error {
+	debugLog("Purging deleted secret '%s'", name)
+	if _, err := client.PurgeDeletedSecret(ctx, name, nil); err != nil {
+		debugLog("Failed to purge secret '%s': %v", name, err)
+		return fmt.Errorf("failed to purge secret '%s': %w", name, err)
+	}
+	if waitDone {
+		err := waitFor(ctx, fmt.Sprintf("purge of secret '%s'", name), func(ctx context.Context) (bool, error) {
+			_, err := client.GetDeletedSecret(ctx, name, nil)
+			exists, err := found(err)
+			return !exists, err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Purged secret '%s'\n", name)
+	return nil
+}
+
+func listDeletedSecrets(cmd *cobra.Command, _ []string) error {
+	if err := requireFlags(cmd, "vault-url"); err != nil {
+		return err
+	}
+
+	setupDebugLogging()
+	client, err := newKeyVaultClient(vaultURL)
+	if err != nil {
+		return err
+	}
+
+	var deleted []*azsecrets.DeletedSecretProperties
+	pager := client.NewListDeletedSecretPropertiesPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(context.Background())
+		if err != nil {
+			debugLog("Failed to list deleted secrets: %v", err)
+			return fmt.Errorf("failed to list deleted secrets: %w", err)
+		}
+		deleted = append(deleted, page.Value...)
+	}
+	debugLog("Found %d deleted secret(s)", len(deleted))
+
+	sort.SliceStable(deleted, func(i, j int) bool {
+		return deleted[i].ID.Name() < deleted[j].ID.Name()
+	})
+	return writeDeletedSecrets(cmd.OutOrStdout(), deleted)
+}
+
+// writeDeletedSecrets prints one row per deleted secret.
+func writeDeletedSecrets(w io.Writer, deleted []*azsecrets.DeletedSecretProperties) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tDELETED\tPURGE SCHEDULED")
+	for _, props := range deleted {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", props.ID.Name(), formatDate(props.DeletedDate), formatDate(props.ScheduledPurgeDate))
+	}
+	return tw.Flush()
+}
+
+// formatDate formats an optional timestamp as RFC 3339, or "-" when unset.
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// confirm asks the user to confirm a destructive operation on stdin, unless
+// --yes was given. Anything but y or yes declines.
+func confirm(cmd *cobra.Command, question string) error {
+	if assumeYes {
+		return nil
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "%s [y/N] ", question)
+	answer, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read confirmation: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	if errors.Is(err, io.EOF) && answer == "" {
+		fmt.Fprintln(cmd.ErrOrStderr())
+		return usageErrorf("confirmation required; pass --yes to run without a prompt")
+	}
+	return errors.New("aborted by user")
+}
+
+// found reports whether err means the resource exists. A not-found response
+// is not an error.
+func found(err error) (bool, error) {
+	if err == nil {
+		return true, nil
+	}
+	if classifyError(err).Code == classNotFound.code {
+		return false, nil
+	}
+	return false, err
+}
+
+// waitFor polls done every pollInterval until it reports true, fails, or
+// waitTimeout has passed.
+func waitFor(ctx context.Context, what string, done func(context.Context) (bool, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, waitTimeout)
+	defer cancel()
+
+	debugLog("Waiting for %s", what)
+	for {
+		ok, err := done(ctx)
+		if ctx.Err() != nil {
+			return fmt.Errorf("timed out after %s waiting for %s", waitTimeout, what)
+		}
+		if err != nil {
+			return fmt.Errorf("failed waiting for %s: %w", what, err)
+		}
+		if ok {
+			debugLog("Completed %s", what)
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out after %s waiting for %s", waitTimeout, what)
+		case <-time.After(pollInterval):
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// shortenPolling makes --wait poll without delay for the duration of a test.
+func shortenPolling(t *testing.T) {
+	t.Helper()
+
+	previousInterval, previousTimeout := pollInterval, waitTimeout
+	pollInterval, waitTimeout = time.Millisecond, 5*time.Second
+	t.Cleanup(func() { pollInterval, waitTimeout = previousInterval, previousTimeout })
+}
+
+func TestDeleteSecret(t *testing.T) {
+	cleanTestEnvironment(t)
+	shortenPolling(t)
+	vault := newFakeVault("deleter")
+	vault.deleteDelay = 2
+	installFakeVaults(t, vault)
+
+	tests := []struct {
+		name          string
+		secret        string
+		args          []string
+		expected      string
+		wantDeleted   bool
+		errorContains string
+	}{
+		{
+			name:        "soft delete",
+			secret:      "soft",
+			args:        []string{"-v", vault.url(), "delete", "soft", "--yes"},
+			expected:    "Deleted secret 'soft'; recoverable until ",
+			wantDeleted: true,
+		},
+		{
+			name:        "wait for deletion",
+			secret:      "waited",
+			args:        []string{"-v", vault.url(), "delete", "waited", "--yes", "--wait"},
+			expected:    "Deleted secret 'waited'; recoverable until ",
+			wantDeleted: true,
+		},
+		{
+			name:     "delete and purge",
+			secret:   "purged",
+			args:     []string{"delete", vault.url() + "secrets/purged", "--purge", "-y"},
+			expected: "Purged secret 'purged'\n",
+		},
+		{
+			name:          "missing secret",
+			secret:        "missing",
+			args:          []string{"-v", vault.url(), "delete", "missing", "--yes"},
+			errorContains: "failed to delete secret 'missing'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.errorContains == "" {
+				vault.add(tt.secret, "value")
+			}
+
+			out, err := executeCommand(t, tt.args...)
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("executeCommand() error = %v; should contain %s", err, tt.errorContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("executeCommand() unexpected error: %v", err)
+			}
+			if !strings.HasPrefix(out, tt.expected) {
+				t.Errorf("executeCommand() = %q; want prefix %q", out, tt.expected)
+			}
+			if _, ok := vault.latest(tt.secret); ok {
+				t.Errorf("secret '%s' still exists", tt.secret)
+			}
+			vault.mu.Lock()
+			_, deleted := vault.deleted[tt.secret]
+			vault.mu.Unlock()
+			if deleted != tt.wantDeleted {
+				t.Errorf("secret '%s' recoverable = %v; want %v", tt.secret, deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestDeleteWithoutSoftDelete(t *testing.T) {
+	cleanTestEnvironment(t)
+	vault := newFakeVault("no-soft-delete")
+	vault.noSoftDelete = true
+	vault.add("gone", "value")
+	installFakeVaults(t, vault)
+
+	out, err := executeCommand(t, "-v", vault.url(), "delete", "gone", "--yes", "--purge")
+	if err != nil {
+		t.Fatalf("executeCommand() unexpected error: %v", err)
+	}
+	if want := "Deleted secret 'gone' permanently (soft-delete is not enabled)\n"; out != want {
+		t.Errorf("executeCommand() = %q; want %q", out, want)
+	}
+}
+
+func TestDeleteConfirmation(t *testing.T) {
+	cleanTestEnvironment(t)
+	vault := newFakeVault("confirmer")
+	installFakeVaults(t, vault)
+
+	previousStdin := stdin
+	t.Cleanup(func() { stdin = previousStdin })
+
+	tests := []struct {
+		name        string
+		answer      string
+		wantDeleted bool
+		exitCode    int
+	}{
+		{name: "yes", answer: "yes\n", wantDeleted: true},
+		{name: "y without newline", answer: "Y", wantDeleted: true},
+		{name: "no", answer: "n\n", exitCode: classError.code},
+		{name: "empty answer", answer: "\n", exitCode: classError.code},
+		{name: "no input", answer: "", exitCode: classUsage.code},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vault.add("confirmed", "value")
+			t.Cleanup(func() {
+				vault.mu.Lock()
+				delete(vault.secrets, "confirmed")
+				delete(vault.deleted, "confirmed")
+				vault.mu.Unlock()
+			})
+
+			stdin = strings.NewReader(tt.answer)
+			_, err := executeCommand(t, "-v", vault.url(), "delete", "confirmed")
+			if tt.wantDeleted {
+				if err != nil {
+					t.Fatalf("executeCommand() unexpected error: %v", err)
+				}
+			} else if err == nil || classifyError(err).Code != tt.exitCode {
+				t.Errorf("executeCommand() error = %v; want exit code %d", err, tt.exitCode)
+			}
+			if _, ok := vault.latest("confirmed"); ok == tt.wantDeleted {
+				t.Errorf("secret exists = %v after answering %q", ok, tt.answer)
+			}
+		})
+	}
+}
+
+func TestRecoverAndPurge(t *testing.T) {
+	cleanTestEnvironment(t)
+	shortenPolling(t)
+	vault := newFakeVault("recoverer")
+	vault.add("restored", "kept")
+	vault.add("discarded", "value")
+	installFakeVaults(t, vault)
+
+	for _, name := range []string{"restored", "discarded"} {
+		if _, err := executeCommand(t, "-v", vault.url(), "delete", name, "--yes", "--wait"); err != nil {
+			t.Fatalf("delete %s: unexpected error: %v", name, err)
+		}
+	}
+
+	out, err := executeCommand(t, "-v", vault.url(), "recover", "restored", "--wait")
+	if err != nil {
+		t.Fatalf("recover: unexpected error: %v", err)
+	}
+	if out != "Recovered secret 'restored'\n" {
+		t.Errorf("recover printed %q", out)
+	}
+	if secret, ok := vault.latest("restored"); !ok || *secret.Value != "kept" {
+		t.Errorf("recovered secret = %v, %v; want value kept", secret.Value, ok)
+	}
+
+	out, err = executeCommand(t, "-v", vault.url(), "purge", "discarded", "--yes", "--wait")
+	if err != nil {
+		t.Fatalf("purge: unexpected error: %v", err)
+	}
+	if out != "Purged secret 'discarded'\n" {
+		t.Errorf("purge printed %q", out)
+	}
+
+	_, err = executeCommand(t, "-v", vault.url(), "recover", "discarded")
+	if err == nil || classifyError(err).Code != classNotFound.code {
+		t.Errorf("recover of purged secret: error = %v; want not found", err)
+	}
+}
+
+func TestListDeleted(t *testing.T) {
+	cleanTestEnvironment(t)
+	vault := newFakeVault("lister")
+	vault.add("beta", "b")
+	vault.add("alpha", "a")
+	vault.add("kept", "k")
+	installFakeVaults(t, vault)
+
+	for _, name := range []string{"beta", "alpha"} {
+		if _, err := executeCommand(t, "-v", vault.url(), "delete", name, "--yes"); err != nil {
+			t.Fatalf("delete %s: unexpected error: %v", name, err)
+		}
+	}
+
+	out, err := executeCommand(t, "-v", vault.url(), "list-deleted")
+	if err != nil {
+		t.Fatalf("executeCommand() unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("list-deleted printed %d lines; want header and 2 secrets:\n%s", len(lines), out)
+	}
+	for i, prefix := range []string{"NAME", "alpha", "beta"} {
+		if !strings.HasPrefix(lines[i], prefix+" ") {
+			t.Errorf("line %d = %q; want it to start with %s", i, lines[i], prefix)
+		}
+	}
+}
+
+func TestDeleteUsageErrors(t *testing.T) {
+	cleanTestEnvironment(t)
+
+	tests := []struct {
+		name          string
+		args          []string
+		errorContains string
+	}{
+		{"delete without vault url", []string{"delete", "name", "--yes"}, `required flag(s) "vault-url" not set`},
+		{"delete without secret", []string{"delete"}, "accepts 1 arg(s), received 0"},
+		{"delete versioned reference", []string{"delete", "https://v.vault.azure.net/secrets/a/v1", "--yes"}, "includes a version"},
+		{"recover without vault url", []string{"recover", "name"}, `required flag(s) "vault-url" not set`},
+		{"purge too many args", []string{"purge", "a", "b"}, "accepts 1 arg(s), received 2"},
+		{"list-deleted without vault url", []string{"list-deleted"}, `required flag(s) "vault-url" not set`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := executeCommand(t, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+				t.Errorf("executeCommand() error = %v; should contain %s", err, tt.errorContains)
+			}
+			if err != nil && classifyError(err).Code != classUsage.code {
+				t.Errorf("executeCommand() error %v is not a usage error", err)
+			}
+		})
+	}
+}
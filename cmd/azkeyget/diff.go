@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffFile       string
+	diffAgainst    string
+	diffShowValues bool
+)
+
+// secretChange is one difference between the vault and what it is compared to.
+type secretChange struct {
+	kind  byte // '+' only in the comparison, '-' only in the vault, '~' changed
+	key   string
+	left  string
+	right string
+}
+
+func newDiffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff (--file <file> | --against <vault>)",
+		Short: "Compare the secrets in a vault to a file or another vault",
+		Long: `Compare the secrets in --vault-url to a dotenv, JSON or YAML file, or to a second
+vault, and list the names that were added (+), removed (-) or changed (~) in
+the comparison. Exits with status 1 when there are differences.
+
+In a dotenv file secret names are compared as environment variable names, as
+--output dotenv writes them, so db-password matches DB_PASSWORD. A JSON or
+YAML file may map names to values or be a list as written by --output json
+or yaml.
+Disabled secrets, which cannot be read, are left out.
+
+Values are never printed unless --show-values is given. Changed values are
+shown as hashes that are keyed afresh on every run, so that they cannot be
+matched against known values.`,
+		Args: usageArgs(cobra.NoArgs),
+		RunE: diffSecrets,
+	}
+
+	cmd.Flags().StringVarP(&diffFile, "file", "f", "", "Dotenv, JSON or YAML file to compare the vault to")
+	cmd.Flags().StringVar(&diffAgainst, "against", "", "Vault URL or name to compare the vault to")
+	cmd.Flags().BoolVar(&diffShowValues, "show-values", false, "Print differing values instead of hashes")
+
+	return cmd
+}
+
+func diffSecrets(cmd *cobra.Command, _ []string) error {
+	if err := requireFlags(cmd, "vault-url"); err != nil {
+		return err
+	}
+	if (diffFile == "") == (diffAgainst == "") {
+		return usageErrorf("exactly one of --file and --against must be given")
+	}
+
+	setupDebugLogging()
+	ctx := context.Background()
+	clients := newVaultClients()
+
+	vault := vaultURLFromName(vaultURL)
+	left, err := vaultValues(ctx, clients, vault)
+	if err != nil {
+		return err
+	}
+
+	right := map[string]string{}
+	if diffFile != "" {
+		debugLog("Comparing %s to %s", vault, diffFile)
+		secrets, format, err := readSecretsFile(diffFile)
+		if err != nil {
+			return withClass(classUsage, err)
+		}
+		for _, secret := range secrets {
+			right[secret.key] = secret.value
+		}
+		if format == "dotenv" {
+			left = byEnvName(left)
+		}
+	} else {
+		other := vaultURLFromName(diffAgainst)
+		debugLog("Comparing %s to %s", vault, other)
+		if right, err = vaultValues(ctx, clients, other); err != nil {
+			return err
+		}
+	}
+
+	changes := compareSecrets(left, right)
+	if err := writeDiff(cmd.OutOrStdout(), changes, diffShowValues); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	if len(changes) > 0 {
+		return &exitCodeError{code: 1}
+	}
+	return nil
+}
+
+// vaultValues retrieves the latest value of every enabled secret in vault.
+func vaultValues(ctx context.Context, clients *vaultClients, vault string) (map[string]string, error) {
+	names, skipped, err := selectSecrets(ctx, clients, vault, listFilter{})
+	if err != nil {
+		return nil, err
+	}
+	if len(skipped) > 0 {
+		debugLog("Leaving out %d disabled or managed secret(s) in %s", len(skipped), vault)
+	}
+
+	refs := make([]secretRef, len(names))
+	for i, name := range names {
+		refs[i] = secretRef{vault: vault, name: name}
+	}
+	results := fetchSecrets(ctx, clients, refs, concurrency, false)
+	if err := checkResults(results, false); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(results))
+	for _, result := range results {
+		values[result.name] = *result.secret.Value
+	}
+	return values, nil
+}
+
+// byEnvName rekeys secret values by their environment variable names.
+func byEnvName(values map[string]string) map[string]string {
+	rekeyed := make(map[string]string, len(values))
+	for name, value := range values {
+		rekeyed[envVarName(name)] = value
+	}
+	return rekeyed
+}
+
+// compareSecrets lists the differences from left to right, sorted by key.
+func compareSecrets(left, right map[string]string) []secretChange {
+	var changes []secretChange
+	for key, value := range left {
+		other, ok := right[key]
+		switch {
+		case !ok:
+			changes = append(changes, secretChange{kind: '-', key: key, left: value})
+		case other != value:
+			changes = append(changes, secretChange{kind: '~', key: key, left: value, right: other})
+		}
+	}
+	for key, value := range right {
+		if _, ok := left[key]; !ok {
+			changes = append(changes, secretChange{kind: '+', key: key, right: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].key < changes[j].key
+	})
+	return changes
+}
+
+// writeDiff prints one line per change and a summary. Values are hashed
+// unless showValues is set.
+func writeDiff(w io.Writer, changes []secretChange, showValues bool) error {
+	show := newValueHasher()
+	if showValues {
+		show = func(value string) string { return fmt.Sprintf("%q", value) }
+	}
+
+	counts := map[byte]int{}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, change := range changes {
+		counts[change.kind]++
+		switch change.kind {
+		case '-':
+			fmt.Fprintf(tw, "- %s\t%s\n", change.key, show(change.left))
+		case '+':
+			fmt.Fprintf(tw, "+ %s\t%s\n", change.key, show(change.right))
+		case '~':
+			fmt.Fprintf(tw, "~ %s\t%s -> %s\n", change.key, show(change.left), show(change.right))
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(w, "No differences")
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d added, %d removed, %d changed\n", counts['+'], counts['-'], counts['~'])
+	return err
+}
+
+// newValueHasher returns a function that abbreviates values to an HMAC-SHA256
+// under a random key, so that equal values hash equally within one run only.
+func newValueHasher() func(string) string {
+	key := make([]byte, 32)
+	rand.Read(key) // never fails
+	return func(value string) string {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(value))
+		return "hmac:" + hex.EncodeToString(mac.Sum(nil))[:12]
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestDiffSecrets(t *testing.T) {
+	cleanTestEnvironment(t)
+	staging := newFakeVault("diff-staging")
+	staging.add("db-password", "hunter2")
+	staging.add("api-key", "staging-key")
+	staging.add("staging-only", "x")
+	staging.add("retired", "old")
+	staging.disable("retired")
+	prod := newFakeVault("diff-prod")
+	prod.add("db-password", "hunter2")
+	prod.add("api-key", "prod-key")
+	prod.add("prod-only", "y")
+	twin := newFakeVault("diff-twin")
+	twin.add("db-password", "hunter2")
+	twin.add("api-key", "staging-key")
+	twin.add("staging-only", "x")
+	installFakeVaults(t, staging, prod, twin)
+
+	dir := t.TempDir()
+	dotenv := filepath.Join(dir, ".env")
+	if err := os.WriteFile(dotenv, []byte("DB_PASSWORD=hunter2\nAPI_KEY='staging-key'\nSTAGING_ONLY=changed\nEXTRA=1\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write dotenv file: %v", err)
+	}
+	jsonFile := filepath.Join(dir, "secrets.json")
+	if err := os.WriteFile(jsonFile, []byte(`{"db-password": "hunter2", "api-key": "staging-key", "staging-only": "x"}`), 0o600); err != nil {
+		t.Fatalf("Failed to write JSON file: %v", err)
+	}
+
+	hash := regexp.MustCompile(`hmac:[0-9a-f]{12}`)
+	tests := []struct {
+		name     string
+		args     []string
+		expected string // hashes replaced by HASH
+		drift    bool
+	}{
+		{
+			name: "against another vault",
+			args: []string{"--against", "diff-prod"},
+			expected: "~ api-key       HASH -> HASH\n" +
+				"+ prod-only     HASH\n" +
+				"- staging-only  HASH\n" +
+				"1 added, 1 removed, 1 changed\n",
+			drift: true,
+		},
+		{
+			name:     "show values",
+			args:     []string{"--against", prod.url(), "--show-values"},
+			expected: "~ api-key       \"staging-key\" -> \"prod-key\"\n+ prod-only     \"y\"\n- staging-only  \"x\"\n1 added, 1 removed, 1 changed\n",
+			drift:    true,
+		},
+		{
+			name:     "identical vaults",
+			args:     []string{"--against", twin.url()},
+			expected: "No differences\n",
+		},
+		{
+			name:     "dotenv file compares environment variable names",
+			args:     []string{"--file", dotenv},
+			expected: "+ EXTRA         HASH\n~ STAGING_ONLY  HASH -> HASH\n1 added, 0 removed, 1 changed\n",
+			drift:    true,
+		},
+		{
+			name:     "json file compares secret names",
+			args:     []string{"-f", jsonFile},
+			expected: "No differences\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := executeCommand(t, append([]string{"-v", staging.url(), "diff"}, tt.args...)...)
+			var exitErr *exitCodeError
+			if tt.drift {
+				if !errors.As(err, &exitErr) || exitErr.code != 1 {
+					t.Errorf("executeCommand() error = %v; want exit status 1", err)
+				}
+			} else if err != nil {
+				t.Fatalf("executeCommand() unexpected error: %v", err)
+			}
+			if got := hash.ReplaceAllString(out, "HASH"); got != tt.expected {
+				t.Errorf("executeCommand() =\n%s\nwant:\n%s", got, tt.expected)
+			}
+			if strings.Contains(out, "prod-key") && !strings.Contains(tt.name, "show values") {
+				t.Errorf("executeCommand() printed a secret value:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestWriteDiffHashes(t *testing.T) {
+	changes := []secretChange{
+		{kind: '~', key: "a", left: "same", right: "other"},
+		{kind: '-', key: "b", left: "same"},
+	}
+
+	var first, second bytes.Buffer
+	if err := writeDiff(&first, changes, false); err != nil {
+		t.Fatalf("writeDiff() unexpected error: %v", err)
+	}
+	if err := writeDiff(&second, changes, false); err != nil {
+		t.Fatalf("writeDiff() unexpected error: %v", err)
+	}
+
+	hashes := regexp.MustCompile(`hmac:[0-9a-f]{12}`).FindAllString(first.String(), -1)
+	if len(hashes) != 3 {
+		t.Fatalf("writeDiff() printed %d hashes; want 3:\n%s", len(hashes), first.String())
+	}
+	if hashes[0] != hashes[2] || hashes[0] == hashes[1] {
+		t.Errorf("hashes %v should be equal for equal values only", hashes)
+	}
+	if first.String() == second.String() {
+		t.Errorf("hashes should be keyed per run, got the same output twice:\n%s", first.String())
+	}
+}
+
+func TestDiffUsageErrors(t *testing.T) {
+	cleanTestEnvironment(t)
+
+	tests := []struct {
+		name          string
+		args          []string
+		errorContains string
+	}{
+		{"no vault url", []string{"diff", "--against", "prod"}, `required flag(s) "vault-url" not set`},
+		{"nothing to compare", []string{"-v", "staging", "diff"}, "exactly one of --file and --against"},
+		{"both file and vault", []string{"-v", "staging", "diff", "--against", "prod", "--file", ".env"}, "exactly one of --file and --against"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := executeCommand(t, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+				t.Errorf("executeCommand() error = %v; should contain %s", err, tt.errorContains)
+			}
+			if err != nil && classifyError(err).Code != classUsage.code {
+				t.Errorf("executeCommand() error %v is not a usage error", err)
+			}
+		})
+	}
+}
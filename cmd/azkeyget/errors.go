@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/spf13/cobra"
+)
+
+// errorFormats lists the values accepted by --error-format.
+var errorFormats = []string{"text", "json"}
+
+// failureClass is a category of failure with its documented exit code.
+type failureClass struct {
+	name string
+	code int
+}
+
+// Failure classes and their exit codes. The codes are part of the CLI
+// contract and documented in the README; do not renumber them.
+var (
+	classError     = failureClass{"error", 1}
+	classUsage     = failureClass{"usage", 2}
+	classAuth      = failureClass{"auth", 3}
+	classForbidden = failureClass{"forbidden", 4}
+	classNotFound  = failureClass{"not_found", 5}
+	classDisabled  = failureClass{"disabled", 6}
+	classThrottled = failureClass{"throttled", 7}
+	classNetwork   = failureClass{"network", 8}
+)
+
+// classifiedError attaches a failure class to an error that azkeyget detects
+// itself, such as an invalid flag combination.
+type classifiedError struct {
+	class failureClass
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+// usageErrorf formats an error for invalid flags or arguments.
+func usageErrorf(format string, args ...interface{}) error {
+	return &classifiedError{class: classUsage, err: fmt.Errorf(format, args...)}
+}
+
+// withClass assigns class to err unless it has already been classified.
+func withClass(class failureClass, err error) error {
+	var classified *classifiedError
+	if errors.As(err, &classified) {
+		return err
+	}
+	return &classifiedError{class: class, err: err}
+}
+
+// usageArgs marks errors from a positional argument validator as usage errors.
+func usageArgs(validate cobra.PositionalArgs) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if err := validate(cmd, args); err != nil {
+			return &classifiedError{class: classUsage, err: err}
+		}
+		return nil
+	}
+}
+
+// errorReport is the machine-readable description of a failure written by
+// --error-format json.
+type errorReport struct {
+	Code      int    `json:"code"`
+	Class     string `json:"class"`
+	Status    int    `json:"status,omitempty"`
+	Message   string `json:"message"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// classifyError determines the failure class of err, together with the HTTP
+// status and request ID of the response that caused it, if any.
+func classifyError(err error) errorReport {
+	report := errorReport{Code: classError.code, Class: classError.name, Message: err.Error()}
+	setClass := func(class failureClass) {
+		report.Code, report.Class = class.code, class.name
+	}
+
+	// Several failed secrets share a class only if every failure has it
+	var retrieval *retrievalError
+	if errors.As(err, &retrieval) {
+		for i, failure := range retrieval.failed {
+			inner := classifyError(failure)
+			if i == 0 {
+				setClass(failureClass{inner.Class, inner.Code})
+			} else if inner.Class != report.Class {
+				setClass(classError)
+				break
+			}
+		}
+		return report
+	}
+
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		report.Status = respErr.StatusCode
+		report.RequestID = requestID(respErr.RawResponse)
+		switch respErr.StatusCode {
+		case http.StatusUnauthorized:
+			setClass(classAuth)
+		case http.StatusForbidden:
+			if isDisabledOrExpired(respErr) {
+				setClass(classDisabled)
+			} else {
+				setClass(classForbidden)
+			}
+		case http.StatusNotFound:
+			setClass(classNotFound)
+		case http.StatusTooManyRequests:
+			setClass(classThrottled)
+		}
+		return report
+	}
+
+	var authErr *azidentity.AuthenticationFailedError
+	if errors.As(err, &authErr) {
+		setClass(classAuth)
+		if authErr.RawResponse != nil {
+			report.Status = authErr.RawResponse.StatusCode
+			report.RequestID = requestID(authErr.RawResponse)
+		}
+		return report
+	}
+
+	var classified *classifiedError
+	switch {
+	case errors.As(err, &classified):
+		setClass(classified.class)
+	case isNetworkError(err):
+		setClass(classNetwork)
+	}
+	return report
+}
+
+// isDisabledOrExpired reports whether a 403 response refused a secret because
+// it is disabled, expired or not yet valid, rather than for lack of access.
+func isDisabledOrExpired(respErr *azcore.ResponseError) bool {
+	message := strings.ToLower(respErr.Error())
+	for _, reason := range []string{"secretdisabled", "disabled secret", "expired", "not yet valid"} {
+		if strings.Contains(message, reason) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNetworkError reports whether err was caused by a connection failure or
+// timeout rather than by a response from Azure.
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// requestID returns the Azure request ID of a response, for support requests.
+func requestID(resp *http.Response) string {
+	if resp == nil {
+		return ""
+	}
+	if id := resp.Header.Get("x-ms-request-id"); id != "" {
+		return id
+	}
+	return resp.Header.Get("x-ms-correlation-id")
+}
+
+// reportError writes err to w in the selected --error-format.
+func reportError(w io.Writer, err error) {
+	if errorFormat == "json" {
+		data, _ := json.Marshal(classifyError(err))
+		fmt.Fprintln(w, string(data))
+		return
+	}
+	fmt.Fprintf(w, "Error: %v\n", err)
+}
+
+// validateErrorFormat checks --error-format against the supported formats.
+func validateErrorFormat(format string) error {
+	for _, supported := range errorFormats {
+		if format == supported {
+			return nil
+		}
+	}
+	return usageErrorf("unsupported error format: %s (supported: %s)", format, strings.Join(errorFormats, ", "))
+}
+
+// authClassifyingCredential classifies token acquisition failures as
+// authentication failures. Connection failures keep their network class.
+type authClassifyingCredential struct {
+	azcore.TokenCredential
+}
+
+func (c authClassifyingCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	token, err := c.TokenCredential.GetToken(ctx, options)
+	if err != nil && !isNetworkError(err) {
+		return token, withClass(classAuth, err)
+	}
+	return token, err
+}
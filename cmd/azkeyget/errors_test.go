@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+// responseError builds an *azcore.ResponseError for status with a Key Vault
+// error body.
+func responseError(status int, code, message string) error {
+	body := fmt.Sprintf(`{"error":{"code":%q,"message":%q}}`, code, message)
+	return runtime.NewResponseError(&http.Response{
+		StatusCode: status,
+		Header: http.Header{
+			"Content-Type":    {"application/json"},
+			"X-Ms-Request-Id": {"request-1"},
+		},
+		Body: io.NopCloser(strings.NewReader(body)),
+	})
+}
+
+func TestClassifyError(t *testing.T) {
+	notFound := fmt.Errorf("failed to get secret 'a': %w", responseError(http.StatusNotFound, "SecretNotFound", "A secret with (name/id) a was not found in this key vault."))
+	forbidden := fmt.Errorf("failed to get secret 'b': %w", responseError(http.StatusForbidden, "Forbidden", "The user does not have secrets get permission."))
+
+	tests := []struct {
+		name      string
+		err       error
+		class     failureClass
+		status    int
+		requestID string
+	}{
+		{"plain error", errors.New("boom"), classError, 0, ""},
+		{"usage error", usageErrorf("--version can only be used with a single secret"), classUsage, 0, ""},
+		{"usage error is not reclassified", withClass(classAuth, fmt.Errorf("failed to create credential: %w", usageErrorf("missing --tenant-id"))), classUsage, 0, ""},
+		{"unauthorized", responseError(http.StatusUnauthorized, "Unauthorized", "AKV10000: Request is missing a Bearer token."), classAuth, http.StatusUnauthorized, "request-1"},
+		{"forbidden", forbidden, classForbidden, http.StatusForbidden, "request-1"},
+		{"disabled secret", disabledSecretError("c"), classDisabled, http.StatusForbidden, "disabled-c"},
+		{"expired secret", responseError(http.StatusForbidden, "Forbidden", "Operation get is not allowed on an expired secret."), classDisabled, http.StatusForbidden, "request-1"},
+		{"not found", notFound, classNotFound, http.StatusNotFound, "request-1"},
+		{"throttled", responseError(http.StatusTooManyRequests, "Throttled", "Rate limit exceeded."), classThrottled, http.StatusTooManyRequests, "request-1"},
+		{"server error", responseError(http.StatusInternalServerError, "InternalServerError", "Internal error."), classError, http.StatusInternalServerError, "request-1"},
+		{"authentication failed", &azidentity.AuthenticationFailedError{}, classAuth, 0, ""},
+		{"token failure", tokenError(errors.New("AADSTS7000215: Invalid client secret provided")), classAuth, 0, ""},
+		{"token connection failure", tokenError(dialError()), classNetwork, 0, ""},
+		{"connection refused", fmt.Errorf("failed to get secret 'a': %w", dialError()), classNetwork, 0, ""},
+		{"timeout", fmt.Errorf("failed to get secret 'a': %w", context.DeadlineExceeded), classNetwork, 0, ""},
+		{"all failures not found", &retrievalError{failed: []error{notFound, notFound}, total: 3}, classNotFound, 0, ""},
+		{"mixed failures", &retrievalError{failed: []error{notFound, forbidden}, total: 3}, classError, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := classifyError(tt.err)
+			if report.Class != tt.class.name || report.Code != tt.class.code {
+				t.Errorf("classifyError() = %s (%d); want %s (%d)", report.Class, report.Code, tt.class.name, tt.class.code)
+			}
+			if report.Status != tt.status {
+				t.Errorf("classifyError() status = %d; want %d", report.Status, tt.status)
+			}
+			if report.RequestID != tt.requestID {
+				t.Errorf("classifyError() requestId = %q; want %q", report.RequestID, tt.requestID)
+			}
+			if report.Message != tt.err.Error() {
+				t.Errorf("classifyError() message = %q; want %q", report.Message, tt.err.Error())
+			}
+		})
+	}
+}
+
+func TestGetSecretErrorClasses(t *testing.T) {
+	vault := newFakeVault("classes")
+	vault.add("alpha", "one")
+	vault.add("retired", "old")
+	vault.disable("retired")
+	installFakeVaults(t, vault)
+
+	tests := []struct {
+		name  string
+		args  []string
+		class failureClass
+	}{
+		{"missing vault URL", []string{"--secret", "alpha"}, classUsage},
+		{"unknown flag", []string{"--no-such-flag"}, classUsage},
+		{"wrong argument count", []string{"versions"}, classUsage},
+		{"invalid output format", []string{"-v", vault.url(), "-s", "alpha", "-o", "xml"}, classUsage},
+		{"secret not found", []string{"-v", vault.url(), "-s", "missing"}, classNotFound},
+		{"secret disabled", []string{"-v", vault.url(), "-s", "retired"}, classDisabled},
+		{"several secrets not found", []string{"-v", vault.url(), "-s", "missing,other"}, classNotFound},
+		{"different failures", []string{"-v", vault.url(), "-s", "missing,retired"}, classError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := executeCommand(t, tt.args...)
+			if err == nil {
+				t.Fatal("executeCommand() expected error but got none")
+			}
+			if report := classifyError(err); report.Code != tt.class.code {
+				t.Errorf("exit code = %d (%s); want %d (%s): %v", report.Code, report.Class, tt.class.code, tt.class.name, err)
+			}
+		})
+	}
+}
+
+func TestReportError(t *testing.T) {
+	previous := errorFormat
+	t.Cleanup(func() { errorFormat = previous })
+	err := fmt.Errorf("failed to get secret 'retired': %w", disabledSecretError("retired"))
+
+	errorFormat = "text"
+	var text bytes.Buffer
+	reportError(&text, err)
+	if !strings.HasPrefix(text.String(), "Error: failed to get secret 'retired'") {
+		t.Errorf("reportError() text = %q; want an Error: line", text.String())
+	}
+
+	errorFormat = "json"
+	var out bytes.Buffer
+	reportError(&out, err)
+	var report map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("reportError() wrote invalid JSON %q: %v", out.String(), err)
+	}
+	want := map[string]interface{}{
+		"code":      float64(classDisabled.code),
+		"class":     classDisabled.name,
+		"status":    float64(http.StatusForbidden),
+		"message":   err.Error(),
+		"requestId": "disabled-retired",
+	}
+	for key, value := range want {
+		if report[key] != value {
+			t.Errorf("reportError() %s = %v; want %v", key, report[key], value)
+		}
+	}
+}
+
+// failingCredential is a token credential whose token requests fail with err.
+type failingCredential struct {
+	err error
+}
+
+func (c failingCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{}, c.err
+}
+
+// tokenError returns the error of a token request made through the credential
+// wrapper that vaultClients installs.
+func tokenError(err error) error {
+	_, err = authClassifyingCredential{failingCredential{err}}.GetToken(context.Background(), policy.TokenRequestOptions{})
+	return err
+}
+
+// dialError is the error returned when a connection is refused.
+func dialError() error {
+	return &url.Error{Op: "Get", URL: "https://classes.vault.azure.net/secrets/a", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var envMappings []string
+
+// envNamePattern matches portable environment variable names.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// envMapping binds an environment variable to the secret that provides its value.
+type envMapping struct {
+	name string
+	ref  secretRef
+}
+
+func newExecCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "exec --env NAME=secret [--env NAME=secret...] -- command [args...]",
+		Short: "Run a command with secrets injected as environment variables",
+		Long: `Resolve every --env mapping and run command with the secret values added to its
+environment. Each secret is either a name in --vault-url or a full secret reference.
+Signals are forwarded to the command and its exit code is passed on.`,
+		Args: usageArgs(cobra.MinimumNArgs(1)),
+		RunE: execCommand,
+	}
+
+	cmd.Flags().StringArrayVarP(&envMappings, "env", "e", nil, "Environment variable mapping NAME=secret (repeatable)")
+	// Flags after the command name belong to the command
+	cmd.Flags().SetInterspersed(false)
+
+	return cmd
+}
+
+func execCommand(cmd *cobra.Command, args []string) error {
+	if len(envMappings) == 0 {
+		return usageErrorf("at least one --env mapping is required")
+	}
+	mappings, err := parseEnvMappings(envMappings)
+	if err != nil {
+		return err
+	}
+	for _, mapping := range mappings {
+		if mapping.ref.vault == "" {
+			if err := requireFlags(cmd, "vault-url"); err != nil {
+				return err
+			}
+		}
+	}
+
+	setupDebugLogging()
+	debugLog("Resolving %d environment variable(s) for %s", len(mappings), args[0])
+
+	refs := make([]secretRef, len(mappings))
+	for i, mapping := range mappings {
+		refs[i] = mapping.ref
+	}
+	clients, err := newRetrievalClients()
+	if err != nil {
+		return err
+	}
+	results := fetchSecrets(context.Background(), clients, refs, concurrency, true)
+	if err := checkResults(results, true); err != nil {
+		return err
+	}
+
+	// Later entries take precedence over inherited variables
+	env := os.Environ()
+	for i, mapping := range mappings {
+		env = append(env, mapping.name+"="+*results[i].secret.Value)
+	}
+
+	code, err := runChild(cmd, args, env)
+	if err != nil {
+		return err
+	}
+	debugLog("Command %s exited with code %d", args[0], code)
+	if code != 0 {
+		return &exitCodeError{code: code}
+	}
+	return nil
+}
+
+// parseEnvMappings parses NAME=secret mappings. Secrets that are not full
+// references are resolved in --vault-url.
+func parseEnvMappings(values []string) ([]envMapping, error) {
+	mappings := make([]envMapping, 0, len(values))
+	for _, value := range values {
+		name, secret, found := strings.Cut(value, "=")
+		if !found || secret == "" {
+			return nil, usageErrorf("invalid --env mapping '%s': expected NAME=secret", value)
+		}
+		if !envNamePattern.MatchString(name) {
+			return nil, usageErrorf("invalid --env mapping '%s': '%s' is not a valid environment variable name", value, name)
+		}
+
+		ref := secretRef{vault: vaultURL, name: secret}
+		if isSecretReference(secret) {
+			parsed, err := parseSecretReference(secret)
+			if err != nil {
+				return nil, withClass(classUsage, err)
+			}
+			ref = parsed
+		}
+		mappings = append(mappings, envMapping{name: name, ref: ref})
+	}
+	return mappings, nil
+}
+
+// runChild runs args with env, forwarding signals until it exits, and returns
+// its exit code.
+func runChild(cmd *cobra.Command, args, env []string) (int, error) {
+	child := exec.Command(args[0], args[1:]...)
+	child.Env = env
+	child.Stdin = cmd.InOrStdin()
+	child.Stdout = cmd.OutOrStdout()
+	child.Stderr = cmd.ErrOrStderr()
+
+	signals := make(chan os.Signal, 8)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	debugLog("Starting command: %s", strings.Join(args, " "))
+	if err := child.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start '%s': %w", args[0], err)
+	}
+
+	// A terminal sends its signals to the whole foreground process group,
+	// which the command is part of, so forwarding them would deliver them
+	// twice; a command such as terraform aborts on a second interrupt
+	terminal := isTerminal(child.Stdin)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if terminal && slices.Contains(terminalSignals, sig) {
+					debugLog("Not forwarding signal %v, which the terminal sent to the command", sig)
+					continue
+				}
+				debugLog("Forwarding signal %v to command", sig)
+				_ = child.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := child.Wait()
+	close(done)
+	if err == nil {
+		return 0, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitStatus(exitErr), nil
+	}
+	return 0, fmt.Errorf("failed to run '%s': %w", args[0], err)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseEnvMappings(t *testing.T) {
+	originalVaultURL := vaultURL
+	vaultURL = "https://default.vault.azure.net/"
+	defer func() { vaultURL = originalVaultURL }()
+
+	tests := []struct {
+		name          string
+		values        []string
+		expected      []envMapping
+		errorContains string
+	}{
+		{
+			name:   "secret name uses vault-url",
+			values: []string{"API_KEY=api-key"},
+			expected: []envMapping{
+				{name: "API_KEY", ref: secretRef{vault: "https://default.vault.azure.net/", name: "api-key"}},
+			},
+		},
+		{
+			name:   "full references select their own vault",
+			values: []string{"DB_PASSWORD=@Microsoft.KeyVault(VaultName=other;SecretName=db;SecretVersion=v1)"},
+			expected: []envMapping{
+				{name: "DB_PASSWORD", ref: secretRef{vault: "https://other.vault.azure.net/", name: "db", version: "v1"}},
+			},
+		},
+		{
+			name:          "missing separator",
+			values:        []string{"API_KEY"},
+			errorContains: "expected NAME=secret",
+		},
+		{
+			name:          "empty secret",
+			values:        []string{"API_KEY="},
+			errorContains: "expected NAME=secret",
+		},
+		{
+			name:          "invalid variable name",
+			values:        []string{"1API-KEY=api-key"},
+			errorContains: "is not a valid environment variable name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mappings, err := parseEnvMappings(tt.values)
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("parseEnvMappings() error = %v; should contain %s", err, tt.errorContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseEnvMappings() unexpected error: %v", err)
+			}
+			if len(mappings) != len(tt.expected) {
+				t.Fatalf("parseEnvMappings() returned %d mappings; want %d", len(mappings), len(tt.expected))
+			}
+			for i := range mappings {
+				if mappings[i] != tt.expected[i] {
+					t.Errorf("mapping[%d] = %+v; want %+v", i, mappings[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExecCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("exec tests use a POSIX shell")
+	}
+
+	vault := newFakeVault("exec")
+	vault.add("api-key", "s3cret")
+	vault.add("db-password", "hunter2")
+	installFakeVaults(t, vault)
+
+	tests := []struct {
+		name          string
+		args          []string
+		expected      string
+		exitCode      int
+		errorContains string
+	}{
+		{
+			name: "secrets are injected into the environment",
+			args: []string{"exec", "--vault-url", vault.url(),
+				"--env", "API_KEY=api-key", "--env", "DB_PASSWORD=db-password",
+				"--", "sh", "-c", `printf '%s:%s' "$API_KEY" "$DB_PASSWORD"`},
+			expected: "s3cret:hunter2",
+		},
+		{
+			name: "command flags are not parsed by azkeyget",
+			args: []string{"exec", "--vault-url", vault.url(), "--env", "API_KEY=api-key",
+				"sh", "-c", `printf '%s' "$1"`, "sh", "--env"},
+			expected: "--env",
+		},
+		{
+			name: "exit code is propagated",
+			args: []string{"exec", "--vault-url", vault.url(), "--env", "API_KEY=api-key",
+				"--", "sh", "-c", "exit 3"},
+			exitCode: 3,
+		},
+		{
+			name: "signals are forwarded to the command",
+			args: []string{"exec", "--vault-url", vault.url(), "--env", "API_KEY=api-key",
+				"--", "sh", "-c", `trap 'exit 7' TERM; kill -TERM $PPID; sleep 5 >/dev/null 2>&1 & wait`},
+			exitCode: 7,
+		},
+		{
+			name: "missing secret does not run the command",
+			args: []string{"exec", "--vault-url", vault.url(), "--env", "API_KEY=missing",
+				"--", "sh", "-c", "echo ran"},
+			errorContains: "failed to get secret 'missing'",
+		},
+		{
+			name:          "no mappings",
+			args:          []string{"exec", "--vault-url", vault.url(), "--", "true"},
+			errorContains: "at least one --env mapping is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := executeCommand(t, tt.args...)
+
+			var exitErr *exitCodeError
+			switch {
+			case tt.errorContains != "":
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("exec error = %v; should contain %s", err, tt.errorContains)
+				}
+				if strings.Contains(out, "ran") {
+					t.Errorf("exec ran the command despite the error")
+				}
+			case tt.exitCode != 0:
+				if !errors.As(err, &exitErr) || exitErr.code != tt.exitCode {
+					t.Errorf("exec error = %v; want exit code %d", err, tt.exitCode)
+				}
+			case err != nil:
+				t.Errorf("exec unexpected error: %v", err)
+			case out != tt.expected:
+				t.Errorf("exec output = %q; want %q", out, tt.expected)
+			}
+		})
+	}
+}
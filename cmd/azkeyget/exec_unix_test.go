@@ -0,0 +1,97 @@
+//go:build !windows
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestExecTerminalSignals(t *testing.T) {
+	cleanTestEnvironment(t)
+	vault := newFakeVault("exec-signals")
+	vault.add("api-key", "s3cret")
+	installFakeVaults(t, vault)
+
+	// The child counts the interrupts it receives until told to stop
+	script := `trap 'echo INT >> "$DIR/count"' INT
+echo $$ > "$DIR/pid.tmp" && mv "$DIR/pid.tmp" "$DIR/pid"
+while [ ! -f "$DIR/stop" ]; do sleep 0.01; done`
+
+	tests := []struct {
+		name     string
+		terminal bool
+		expected int
+	}{
+		// /dev/null stands in for a terminal, as both are character devices
+		{name: "stdin is a terminal", terminal: true, expected: 1},
+		{name: "stdin is a pipe", terminal: false, expected: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv("DIR", dir)
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			var in io.Reader = strings.NewReader("")
+			if tt.terminal {
+				devNull, err := os.Open(os.DevNull)
+				if err != nil {
+					t.Fatalf("Failed to open %s: %v", os.DevNull, err)
+				}
+				defer devNull.Close()
+				in = devNull
+			}
+
+			cmd := newRootCmd()
+			cmd.SetArgs([]string{"exec", "--vault-url", vault.url(), "--env", "API_KEY=api-key", "--", "sh", "-c", script})
+			cmd.SetIn(in)
+			cmd.SetOut(&bytes.Buffer{})
+			cmd.SetErr(io.Discard)
+			done := make(chan error, 1)
+			go func() { done <- cmd.Execute() }()
+
+			var pid int
+			deadline := time.Now().Add(5 * time.Second)
+			for pid == 0 {
+				if data, err := os.ReadFile(filepath.Join(dir, "pid")); err == nil {
+					pid, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+				}
+				if time.Now().After(deadline) {
+					t.Fatal("exec did not start the command")
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			// A Ctrl-C reaches both the command and azkeyget. The signals are
+			// sent apart, as the shell runs its trap once for interrupts that
+			// arrive together
+			if err := syscall.Kill(pid, syscall.SIGINT); err != nil {
+				t.Fatalf("Failed to signal the command: %v", err)
+			}
+			waitForFile(t, filepath.Join(dir, "count"), "INT\n")
+			if err := syscall.Kill(os.Getpid(), syscall.SIGINT); err != nil {
+				t.Fatalf("Failed to signal azkeyget: %v", err)
+			}
+			time.Sleep(200 * time.Millisecond)
+			if err := os.WriteFile(filepath.Join(dir, "stop"), nil, 0o600); err != nil {
+				t.Fatalf("Failed to stop the command: %v", err)
+			}
+			if err := <-done; err != nil {
+				t.Fatalf("exec unexpected error: %v", err)
+			}
+
+			data, _ := os.ReadFile(filepath.Join(dir, "count"))
+			if got := strings.Count(string(data), "INT"); got != tt.expected {
+				t.Errorf("command received %d SIGINT(s); want %d", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// forwardedSignals are passed on to the command run by exec. Console control
+// events already reach every process in the console, so this is best effort.
+var forwardedSignals = []os.Signal{os.Interrupt}
+
+// terminalSignals are the forwarded signals that the console sends to every
+// process attached to it.
+var terminalSignals = []os.Signal{os.Interrupt}
+
+func exitStatus(err *exec.ExitError) int {
+	return err.ExitCode()
+}
+
+// parseSignal fails on Windows, which cannot send signals to other processes.
+func parseSignal(string) (os.Signal, error) {
+	return nil, usageErrorf("--signal is not supported on Windows; use --reload-command")
+}
+
+// shellCommand runs command with the system shell.
+func shellCommand(command string) *exec.Cmd {
+	return exec.Command("cmd", "/C", command)
+}
+
+func signalProcess(int, os.Signal) error {
+	return errors.New("signals are not supported on Windows")
+}
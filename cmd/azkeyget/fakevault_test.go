@@ -0,0 +1,470 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets/fake"
+)
+
+// fakeVault is an in-memory Key Vault backed by the azsecrets fake server.
+type fakeVault struct {
+	host string
+
+	mu       sync.Mutex
+	secrets  map[string][]azsecrets.Secret // versions oldest first
+	deleted  map[string]*fakeDeletedSecret
+	versions int
+	gets     int
+
+	// noSoftDelete makes deletions permanent, as in vaults without soft-delete.
+	noSoftDelete bool
+	// deleteDelay is the number of GetDeletedSecret calls that report a
+	// deleted secret as not found yet, as while a deletion is in progress.
+	deleteDelay int
+}
+
+// fakeBackup is the content of the backup blobs of the fake vault. Key Vault
+// blobs are opaque and encrypted.
+type fakeBackup struct {
+	Name     string             `json:"name"`
+	Versions []azsecrets.Secret `json:"versions"`
+}
+
+// fakeDeletedSecret is a soft-deleted secret with all of its versions.
+type fakeDeletedSecret struct {
+	versions  []azsecrets.Secret
+	deletedAt time.Time
+	pending   int // GetDeletedSecret calls left before the deletion completes
+}
+
+// newFakeVault creates an empty fake vault reachable at https://<name>.vault.azure.net/.
+func newFakeVault(name string) *fakeVault {
+	return &fakeVault{
+		host:    name + ".vault.azure.net",
+		secrets: map[string][]azsecrets.Secret{},
+		deleted: map[string]*fakeDeletedSecret{},
+	}
+}
+
+// url returns the vault URL of the fake vault.
+func (v *fakeVault) url() string {
+	return "https://" + v.host + "/"
+}
+
+// add stores a new version of a secret and returns its version ID.
+func (v *fakeVault) add(name, value string) string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	secret := v.store(name, azsecrets.Secret{
+		Value:      to.Ptr(value),
+		Attributes: &azsecrets.SecretAttributes{Enabled: to.Ptr(true)},
+	})
+	return secret.ID.Version()
+}
+
+// store appends secret as the latest version of name, assigning its ID and
+// timestamps. The caller must hold v.mu.
+func (v *fakeVault) store(name string, secret azsecrets.Secret) azsecrets.Secret {
+	v.versions++
+	now := time.Now().UTC()
+	id := azsecrets.ID(fmt.Sprintf("https://%s/secrets/%s/%032x", v.host, name, v.versions))
+	attributes := azsecrets.SecretAttributes{}
+	if secret.Attributes != nil {
+		attributes = *secret.Attributes
+	}
+	if attributes.Enabled == nil {
+		attributes.Enabled = to.Ptr(true)
+	}
+	attributes.Created, attributes.Updated = &now, &now
+	secret.ID, secret.Attributes = &id, &attributes
+	v.secrets[name] = append(v.secrets[name], secret)
+	return secret
+}
+
+// put stores secret, with its metadata, as a new version of name and returns
+// its version ID.
+func (v *fakeVault) put(name string, secret azsecrets.Secret) string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.store(name, secret).ID.Version()
+}
+
+// disable marks every version of a secret as disabled. Key Vault refuses to
+// return the value of a disabled secret.
+func (v *fakeVault) disable(name string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for _, secret := range v.secrets[name] {
+		secret.Attributes.Enabled = to.Ptr(false)
+	}
+}
+
+// latest returns the latest version of a secret.
+func (v *fakeVault) latest(name string) (azsecrets.Secret, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.lookup(name, "")
+}
+
+// getCount returns the number of GetSecret calls served.
+func (v *fakeVault) getCount() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.gets
+}
+
+func (v *fakeVault) server() *fake.Server {
+	return &fake.Server{
+		GetSecret: func(_ context.Context, name, version string, _ *azsecrets.GetSecretOptions) (resp azfake.Responder[azsecrets.GetSecretResponse], errResp azfake.ErrorResponder) {
+			v.mu.Lock()
+			defer v.mu.Unlock()
+			v.gets++
+
+			name, version = splitFakePath(name, version)
+			secret, ok := v.lookup(name, version)
+			if !ok {
+				errResp.SetResponseError(http.StatusNotFound, "SecretNotFound")
+				return resp, errResp
+			}
+			if !*secret.Attributes.Enabled {
+				errResp.SetError(disabledSecretError(name))
+				return resp, errResp
+			}
+			resp.SetResponse(http.StatusOK, azsecrets.GetSecretResponse{Secret: secret}, nil)
+			return resp, errResp
+		},
+		SetSecret: func(_ context.Context, name string, parameters azsecrets.SetSecretParameters, _ *azsecrets.SetSecretOptions) (resp azfake.Responder[azsecrets.SetSecretResponse], errResp azfake.ErrorResponder) {
+			v.mu.Lock()
+			defer v.mu.Unlock()
+
+			// A deleted secret blocks its name until it is recovered or purged
+			if _, ok := v.deleted[name]; ok {
+				errResp.SetResponseError(http.StatusConflict, "Conflict")
+				return resp, errResp
+			}
+			secret := v.store(name, azsecrets.Secret{
+				Value:       parameters.Value,
+				ContentType: parameters.ContentType,
+				Tags:        parameters.Tags,
+				Attributes:  parameters.SecretAttributes,
+			})
+			resp.SetResponse(http.StatusOK, azsecrets.SetSecretResponse{Secret: secret}, nil)
+			return resp, errResp
+		},
+		DeleteSecret: func(_ context.Context, name string, _ *azsecrets.DeleteSecretOptions) (resp azfake.Responder[azsecrets.DeleteSecretResponse], errResp azfake.ErrorResponder) {
+			v.mu.Lock()
+			defer v.mu.Unlock()
+
+			versions, ok := v.secrets[name]
+			if !ok {
+				errResp.SetResponseError(http.StatusNotFound, "SecretNotFound")
+				return resp, errResp
+			}
+			delete(v.secrets, name)
+			deleted := &fakeDeletedSecret{versions: versions, deletedAt: time.Now().UTC(), pending: v.deleteDelay}
+			if v.noSoftDelete {
+				resp.SetResponse(http.StatusOK, azsecrets.DeleteSecretResponse{DeletedSecret: azsecrets.DeletedSecret{ID: versions[len(versions)-1].ID}}, nil)
+				return resp, errResp
+			}
+			v.deleted[name] = deleted
+			resp.SetResponse(http.StatusOK, azsecrets.DeleteSecretResponse{DeletedSecret: v.deletedSecret(name, deleted)}, nil)
+			return resp, errResp
+		},
+		GetDeletedSecret: func(_ context.Context, name string, _ *azsecrets.GetDeletedSecretOptions) (resp azfake.Responder[azsecrets.GetDeletedSecretResponse], errResp azfake.ErrorResponder) {
+			v.mu.Lock()
+			defer v.mu.Unlock()
+
+			deleted, ok := v.deleted[name]
+			if ok && deleted.pending > 0 {
+				deleted.pending--
+				ok = false
+			}
+			if !ok {
+				errResp.SetResponseError(http.StatusNotFound, "SecretNotFound")
+				return resp, errResp
+			}
+			resp.SetResponse(http.StatusOK, azsecrets.GetDeletedSecretResponse{DeletedSecret: v.deletedSecret(name, deleted)}, nil)
+			return resp, errResp
+		},
+		RecoverDeletedSecret: func(_ context.Context, name string, _ *azsecrets.RecoverDeletedSecretOptions) (resp azfake.Responder[azsecrets.RecoverDeletedSecretResponse], errResp azfake.ErrorResponder) {
+			v.mu.Lock()
+			defer v.mu.Unlock()
+
+			deleted, ok := v.deleted[name]
+			if !ok {
+				errResp.SetResponseError(http.StatusNotFound, "SecretNotFound")
+				return resp, errResp
+			}
+			delete(v.deleted, name)
+			v.secrets[name] = deleted.versions
+			latest := deleted.versions[len(deleted.versions)-1]
+			resp.SetResponse(http.StatusOK, azsecrets.RecoverDeletedSecretResponse{Secret: azsecrets.Secret{ID: latest.ID, Attributes: latest.Attributes}}, nil)
+			return resp, errResp
+		},
+		PurgeDeletedSecret: func(_ context.Context, name string, _ *azsecrets.PurgeDeletedSecretOptions) (resp azfake.Responder[azsecrets.PurgeDeletedSecretResponse], errResp azfake.ErrorResponder) {
+			v.mu.Lock()
+			defer v.mu.Unlock()
+
+			if _, ok := v.deleted[name]; !ok {
+				errResp.SetResponseError(http.StatusNotFound, "SecretNotFound")
+				return resp, errResp
+			}
+			delete(v.deleted, name)
+			resp.SetResponse(http.StatusNoContent, azsecrets.PurgeDeletedSecretResponse{}, nil)
+			return resp, errResp
+		},
+		BackupSecret: func(_ context.Context, name string, _ *azsecrets.BackupSecretOptions) (resp azfake.Responder[azsecrets.BackupSecretResponse], errResp azfake.ErrorResponder) {
+			v.mu.Lock()
+			defer v.mu.Unlock()
+
+			versions, ok := v.secrets[name]
+			if !ok {
+				errResp.SetResponseError(http.StatusNotFound, "SecretNotFound")
+				return resp, errResp
+			}
+			blob, err := json.Marshal(fakeBackup{Name: name, Versions: versions})
+			if err != nil {
+				errResp.SetError(err)
+				return resp, errResp
+			}
+			resp.SetResponse(http.StatusOK, azsecrets.BackupSecretResponse{BackupSecretResult: azsecrets.BackupSecretResult{Value: blob}}, nil)
+			return resp, errResp
+		},
+		RestoreSecret: func(_ context.Context, parameters azsecrets.RestoreSecretParameters, _ *azsecrets.RestoreSecretOptions) (resp azfake.Responder[azsecrets.RestoreSecretResponse], errResp azfake.ErrorResponder) {
+			v.mu.Lock()
+			defer v.mu.Unlock()
+
+			var backup fakeBackup
+			if err := json.Unmarshal(parameters.SecretBackup, &backup); err != nil || len(backup.Versions) == 0 {
+				errResp.SetResponseError(http.StatusBadRequest, "BadParameter")
+				return resp, errResp
+			}
+			_, exists := v.secrets[backup.Name]
+			if _, deleted := v.deleted[backup.Name]; exists || deleted {
+				errResp.SetResponseError(http.StatusConflict, "Conflict")
+				return resp, errResp
+			}
+			// Restored versions keep their version IDs in the new vault
+			for i, secret := range backup.Versions {
+				id := azsecrets.ID(fmt.Sprintf("https://%s/secrets/%s/%s", v.host, backup.Name, secret.ID.Version()))
+				backup.Versions[i].ID = &id
+			}
+			v.secrets[backup.Name] = backup.Versions
+			latest := backup.Versions[len(backup.Versions)-1]
+			resp.SetResponse(http.StatusOK, azsecrets.RestoreSecretResponse{Secret: azsecrets.Secret{ID: latest.ID, Attributes: latest.Attributes}}, nil)
+			return resp, errResp
+		},
+		NewListDeletedSecretPropertiesPager: func(_ *azsecrets.ListDeletedSecretPropertiesOptions) (resp azfake.PagerResponder[azsecrets.ListDeletedSecretPropertiesResponse]) {
+			v.mu.Lock()
+			defer v.mu.Unlock()
+
+			for name, deleted := range v.deleted {
+				secret := v.deletedSecret(name, deleted)
+				resp.AddPage(http.StatusOK, azsecrets.ListDeletedSecretPropertiesResponse{
+					DeletedSecretPropertiesListResult: azsecrets.DeletedSecretPropertiesListResult{
+						Value: []*azsecrets.DeletedSecretProperties{{
+							ID:                 secret.ID,
+							Attributes:         secret.Attributes,
+							RecoveryID:         secret.RecoveryID,
+							DeletedDate:        secret.DeletedDate,
+							ScheduledPurgeDate: secret.ScheduledPurgeDate,
+						}},
+					},
+				}, nil)
+			}
+			if len(v.deleted) == 0 {
+				resp.AddPage(http.StatusOK, azsecrets.ListDeletedSecretPropertiesResponse{}, nil)
+			}
+			return resp
+		},
+		NewListSecretPropertiesPager: func(_ *azsecrets.ListSecretPropertiesOptions) (resp azfake.PagerResponder[azsecrets.ListSecretPropertiesResponse]) {
+			v.mu.Lock()
+			defer v.mu.Unlock()
+
+			// One secret per page exercises pagination. Listed IDs carry no
+			// version, as in Key Vault.
+			for name, versions := range v.secrets {
+				props := secretProperties(versions[len(versions)-1])
+				id := azsecrets.ID(fmt.Sprintf("https://%s/secrets/%s", v.host, name))
+				props.ID = &id
+				resp.AddPage(http.StatusOK, azsecrets.ListSecretPropertiesResponse{
+					SecretPropertiesListResult: azsecrets.SecretPropertiesListResult{
+						Value: []*azsecrets.SecretProperties{props},
+					},
+				}, nil)
+			}
+			if len(v.secrets) == 0 {
+				resp.AddPage(http.StatusOK, azsecrets.ListSecretPropertiesResponse{}, nil)
+			}
+			return resp
+		},
+		NewListSecretPropertiesVersionsPager: func(name string, _ *azsecrets.ListSecretPropertiesVersionsOptions) (resp azfake.PagerResponder[azsecrets.ListSecretPropertiesVersionsResponse]) {
+			v.mu.Lock()
+			defer v.mu.Unlock()
+
+			// One version per page exercises pagination
+			for _, secret := range v.secrets[name] {
+				resp.AddPage(http.StatusOK, azsecrets.ListSecretPropertiesVersionsResponse{
+					SecretPropertiesListResult: azsecrets.SecretPropertiesListResult{
+						Value: []*azsecrets.SecretProperties{secretProperties(secret)},
+					},
+				}, nil)
+			}
+			return resp
+		},
+	}
+}
+
+// disabledSecretError builds the response error Key Vault returns when a
+// disabled secret is read.
+func disabledSecretError(name string) error {
+	body := `{"error":{"code":"Forbidden","message":"Operation get is not allowed on a disabled secret.","innererror":{"code":"SecretDisabled"}}}`
+	return runtime.NewResponseError(&http.Response{
+		StatusCode: http.StatusForbidden,
+		Header: http.Header{
+			"Content-Type":    {"application/json"},
+			"X-Ms-Request-Id": {"disabled-" + name},
+		},
+		Body: io.NopCloser(strings.NewReader(body)),
+	})
+}
+
+// deletedSecret describes a soft-deleted secret by its latest version. The
+// caller must hold v.mu.
+func (v *fakeVault) deletedSecret(name string, deleted *fakeDeletedSecret) azsecrets.DeletedSecret {
+	latest := deleted.versions[len(deleted.versions)-1]
+	purgeDate := deleted.deletedAt.Add(90 * 24 * time.Hour)
+	return azsecrets.DeletedSecret{
+		ID:                 latest.ID,
+		Attributes:         latest.Attributes,
+		RecoveryID:         to.Ptr(fmt.Sprintf("https://%s/deletedsecrets/%s", v.host, name)),
+		DeletedDate:        &deleted.deletedAt,
+		ScheduledPurgeDate: &purgeDate,
+	}
+}
+
+// secretProperties returns the metadata of a secret without its value.
+func secretProperties(secret azsecrets.Secret) *azsecrets.SecretProperties {
+	return &azsecrets.SecretProperties{
+		ID:          secret.ID,
+		Attributes:  secret.Attributes,
+		ContentType: secret.ContentType,
+		Tags:        secret.Tags,
+	}
+}
+
+// lookup finds a secret version; an empty version selects the latest. The
+// caller must hold v.mu.
+func (v *fakeVault) lookup(name, version string) (azsecrets.Secret, bool) {
+	versions := v.secrets[name]
+	if len(versions) == 0 {
+		return azsecrets.Secret{}, false
+	}
+	if version == "" {
+		return versions[len(versions)-1], true
+	}
+	for _, secret := range versions {
+		if secret.ID.Version() == version {
+			return secret, true
+		}
+	}
+	return azsecrets.Secret{}, false
+}
+
+// splitFakePath separates a version that the fake server's greedy path
+// pattern captured as part of the secret name.
+func splitFakePath(name, version string) (string, string) {
+	if before, after, found := strings.Cut(name, "/"); found {
+		name = before
+		if version == "" {
+			version = after
+		}
+	}
+	return name, version
+}
+
+// fakeRouter dispatches requests to the fake vault matching the request host.
+type fakeRouter map[string]*fake.ServerTransport
+
+func (r fakeRouter) Do(req *http.Request) (*http.Response, error) {
+	transport, ok := r[req.URL.Host]
+	if !ok {
+		return nil, fmt.Errorf("no fake vault for host %s", req.URL.Host)
+	}
+	return transport.Do(req)
+}
+
+// installFakeVaults points every Key Vault client created by the CLI at the
+// given fake vaults, using a fake credential. The previous seams are restored
+// when the test finishes.
+func installFakeVaults(t *testing.T, vaults ...*fakeVault) {
+	t.Helper()
+
+	router := fakeRouter{}
+	for _, vault := range vaults {
+		router[vault.host] = fake.NewServerTransport(vault.server())
+	}
+
+	previousCredential, previousOptions := newCredential, clientOptions
+	t.Cleanup(func() {
+		newCredential, clientOptions = previousCredential, previousOptions
+	})
+
+	newCredential = func(authSettings) (azcore.TokenCredential, error) {
+		return &azfake.TokenCredential{}, nil
+	}
+	clientOptions = &azsecrets.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Transport: router,
+			Retry:     policy.RetryOptions{MaxRetries: -1},
+		},
+	}
+}
+
+// newFakeClient returns a client for a fake vault installed with installFakeVaults.
+func newFakeClient(t *testing.T, vault *fakeVault) *azsecrets.Client {
+	t.Helper()
+
+	credential, err := newCredential(flagAuthSettings())
+	if err != nil {
+		t.Fatalf("newCredential() unexpected error: %v", err)
+	}
+	client, err := azsecrets.NewClient(vault.url(), credential, clientOptions)
+	if err != nil {
+		t.Fatalf("azsecrets.NewClient() unexpected error: %v", err)
+	}
+	return client
+}
+
+// executeCommand runs the azkeyget command tree with args and returns what it
+// wrote to stdout. The user's configuration file is ignored.
+func executeCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	var out bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetArgs(args)
+	cmd.SetOut(&out)
+	cmd.SetErr(io.Discard)
+	err := cmd.Execute()
+	return out.String(), err
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	githubActions bool
+	githubEnv     bool
+	githubOutput  bool
+)
+
+// githubCommandEscaper escapes the data of a GitHub Actions workflow command.
+var githubCommandEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+
+// addGitHubActionsFlags adds the flags of the GitHub Actions integration. It
+// is enabled by default inside GitHub Actions, which sets GITHUB_ACTIONS.
+func addGitHubActionsFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&githubActions, "github-actions", getEnvOrDefaultBool("GITHUB_ACTIONS", false), "Mask retrieved values in GitHub Actions logs (default: true inside GitHub Actions)")
+	cmd.Flags().BoolVar(&githubEnv, "github-env", false, "Append the secrets to $GITHUB_ENV instead of writing them to stdout")
+	cmd.Flags().BoolVar(&githubOutput, "github-output", false, "Append the secrets to $GITHUB_OUTPUT instead of writing them to stdout")
+}
+
+// githubFiles returns the files named by $GITHUB_ENV and $GITHUB_OUTPUT that
+// --github-env and --github-output select.
+func githubFiles() ([]string, error) {
+	var files []string
+	for _, target := range []struct {
+		enabled bool
+		flag    string
+		env     string
+	}{
+		{githubEnv, "--github-env", "GITHUB_ENV"},
+		{githubOutput, "--github-output", "GITHUB_OUTPUT"},
+	} {
+		if !target.enabled {
+			continue
+		}
+		path := os.Getenv(target.env)
+		if path == "" {
+			return nil, usageErrorf("%s requires $%s, which GitHub Actions sets for each step", target.flag, target.env)
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// writeGitHubMasks writes an add-mask workflow command for each line of every
+// retrieved value. GitHub Actions masks multiline values line by line, so
+// masking a value whole would leave its lines visible.
+func writeGitHubMasks(w io.Writer, results []secretResult) {
+	for _, result := range results {
+		if result.err != nil {
+			continue
+		}
+		for _, line := range strings.Split(*result.secret.Value, "\n") {
+			line = strings.TrimSuffix(line, "\r")
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			fmt.Fprintf(w, "::add-mask::%s\n", githubCommandEscaper.Replace(line))
+		}
+	}
+}
+
+// appendGitHubFile appends every retrieved secret to a GitHub Actions
+// environment or output file as a NAME<<DELIMITER block. The delimiters are
+// random, so that a value cannot end its block early and set other variables.
+func appendGitHubFile(path string, results []secretResult) error {
+	var buf bytes.Buffer
+	written := 0
+	for _, result := range results {
+		if result.err != nil {
+			continue
+		}
+		output := newSecretOutput(result)
+		delimiter, err := githubDelimiter(output.Value)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "%s<<%s\n%s\n%s\n", output.envName(), delimiter, output.Value, delimiter)
+		written++
+	}
+
+	debugLog("Appending %d secret(s) to %s", written, path)
+	return appendFile(path, buf.Bytes())
+}
+
+// appendFile appends data to the file at path with a single write, creating
+// the file if needed.
+func appendFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// githubDelimiter returns a random heredoc delimiter that does not occur in value.
+func githubDelimiter(value string) (string, error) {
+	for {
+		random := make([]byte, 16)
+		if _, err := rand.Read(random); err != nil {
+			return "", fmt.Errorf("failed to generate delimiter: %w", err)
+		}
+		delimiter := "ghadelimiter_" + hex.EncodeToString(random)
+		if !strings.Contains(value, delimiter) {
+			return delimiter, nil
+		}
+	}
+}
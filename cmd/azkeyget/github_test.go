@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestWriteGitHubMasks(t *testing.T) {
+	failed := secretResult{name: "missing", err: os.ErrNotExist}
+	results := []secretResult{
+		testResult("api-key", "s3cret"),
+		testResult("tls-key", "-----BEGIN KEY-----\r\nabc\r\n\n-----END KEY-----\n"),
+		testResult("odd", "100%\rdone"),
+		testResult("blank", "  "),
+		failed,
+	}
+
+	var out bytes.Buffer
+	writeGitHubMasks(&out, results)
+	expected := strings.Join([]string{
+		"::add-mask::s3cret",
+		"::add-mask::-----BEGIN KEY-----",
+		"::add-mask::abc",
+		"::add-mask::-----END KEY-----",
+		"::add-mask::100%25%0Ddone",
+	}, "\n") + "\n"
+	if out.String() != expected {
+		t.Errorf("writeGitHubMasks() = %q; want %q", out.String(), expected)
+	}
+}
+
+func TestGitHubActions(t *testing.T) {
+	cleanTestEnvironment(t)
+	vault := newFakeVault("github")
+	vault.add("db-password", "hunter2")
+	vault.add("tls-key", "line one\nline two")
+	installFakeVaults(t, vault)
+
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, "github_env")
+	outputFile := filepath.Join(dir, "github_output")
+	if err := os.WriteFile(envFile, []byte("EXISTING=1\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write env file: %v", err)
+	}
+	t.Setenv("GITHUB_ENV", envFile)
+	t.Setenv("GITHUB_OUTPUT", outputFile)
+
+	// run executes azkeyget and returns its stdout and stderr
+	run := func(args ...string) (string, string) {
+		t.Helper()
+		t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+		var stdout, stderr bytes.Buffer
+		cmd := newRootCmd()
+		cmd.SetArgs(append([]string{"-v", vault.url(), "-s", "db-password,tls-key"}, args...))
+		cmd.SetOut(&stdout)
+		cmd.SetErr(&stderr)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Execute(%v) unexpected error: %v", args, err)
+		}
+		return stdout.String(), stderr.String()
+	}
+	masks := "::add-mask::hunter2\n::add-mask::line one\n::add-mask::line two\n"
+
+	stdout, stderr := run("--github-env", "--github-output")
+	if stdout != "" {
+		t.Errorf("stdout = %q; want the secrets written to the GitHub files only", stdout)
+	}
+	if stderr != masks {
+		t.Errorf("stderr = %q; want %q", stderr, masks)
+	}
+	block := regexp.MustCompile(`^(\w+)<<(ghadelimiter_[0-9a-f]{32})\n((?s:.*?))\n(ghadelimiter_[0-9a-f]{32})\n`)
+	for _, file := range []string{envFile, outputFile} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		content := strings.TrimPrefix(string(data), "EXISTING=1\n")
+		values := map[string]string{}
+		var delimiters []string
+		for content != "" {
+			m := block.FindStringSubmatch(content)
+			if m == nil || m[2] != m[4] {
+				t.Fatalf("%s holds a malformed block: %q", file, content)
+			}
+			values[m[1]] = m[3]
+			delimiters = append(delimiters, m[2])
+			content = content[len(m[0]):]
+		}
+		if values["DB_PASSWORD"] != "hunter2" || values["TLS_KEY"] != "line one\nline two" || len(values) != 2 {
+			t.Errorf("%s values = %q", file, values)
+		}
+		if len(delimiters) == 2 && delimiters[0] == delimiters[1] {
+			t.Errorf("%s reuses delimiter %s", file, delimiters[0])
+		}
+	}
+	if data, _ := os.ReadFile(envFile); !strings.HasPrefix(string(data), "EXISTING=1\n") {
+		t.Errorf("%s was not appended to: %q", envFile, data)
+	}
+
+	// Inside GitHub Actions values are masked, and stdout is unchanged
+	t.Setenv("GITHUB_ACTIONS", "true")
+	stdout, stderr = run("-o", "dotenv")
+	if stdout != "DB_PASSWORD=hunter2\nTLS_KEY=\"line one\\nline two\"\n" || stderr != masks {
+		t.Errorf("auto-detected run = %q, %q; want dotenv output and masks", stdout, stderr)
+	}
+	if _, stderr = run("--github-actions=false"); stderr != "" {
+		t.Errorf("stderr with --github-actions=false = %q; want no masks", stderr)
+	}
+}
+
+func TestGitHubFilesRequireEnvironment(t *testing.T) {
+	cleanTestEnvironment(t)
+
+	for _, flag := range []string{"--github-env", "--github-output"} {
+		_, err := executeCommand(t, "-v", "https://vault.vault.azure.net/", "-s", "x", flag)
+		if err == nil || !strings.Contains(err.Error(), flag+" requires $GITHUB_") {
+			t.Errorf("%s error = %v; want the GitHub file required", flag, err)
+		}
+		if err != nil && classifyError(err).Code != classUsage.code {
+			t.Errorf("%s error %v is not a usage error", flag, err)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/spf13/cobra"
+)
+
+// nameTransforms lists the values accepted by import --name-transform.
+var nameTransforms = []string{"kebab", "hyphen", "none"}
+
+// secretNamePattern matches the names Key Vault accepts for secrets.
+var secretNamePattern = regexp.MustCompile(`^[0-9A-Za-z-]{1,127}$`)
+
+var (
+	importFile          string
+	importNameTransform string
+	importTags          map[string]string
+	importDryRun        bool
+	importSkipExisting  bool
+)
+
+// importEntry is one key of the imported file and what happens to it.
+type importEntry struct {
+	key    string
+	name   string
+	value  string
+	action writeAction
+	err    error
+}
+
+func newImportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import --file <file>",
+		Short: "Store the values of a dotenv, JSON or YAML file as secrets",
+		Long: `Store every key of a dotenv, JSON or YAML file as a secret in --vault-url. Each key
+gets a new version, or is left alone with --skip-existing if the secret already
+exists.
+
+Key Vault secret names may only contain letters, digits and hyphens, so keys
+are converted with --name-transform:
+
+  kebab   lower case, with underscores and dots as hyphens (DB_PASSWORD -> db-password)
+  hyphen  underscores and dots as hyphens, keeping case (DB_PASSWORD -> DB-PASSWORD)
+  none    keys are used unchanged and must already be valid names`,
+		Args: usageArgs(cobra.NoArgs),
+		RunE: importSecrets,
+	}
+
+	cmd.Flags().StringVarP(&importFile, "file", "f", "", "Dotenv, JSON or YAML file to import (required)")
+	cmd.Flags().StringVar(&importNameTransform, "name-transform", "kebab", "How keys become secret names: kebab, hyphen, none")
+	cmd.Flags().StringToStringVar(&importTags, "tags", nil, "Tags for every imported secret as key=value, repeatable or comma-separated")
+	cmd.Flags().BoolVar(&importDryRun, "dry-run", false, "Show what would be created, updated or skipped without writing")
+	cmd.Flags().BoolVar(&importSkipExisting, "skip-existing", false, "Leave secrets that already exist unchanged")
+
+	return cmd
+}
+
+func importSecrets(cmd *cobra.Command, _ []string) error {
+	if err := requireFlags(cmd, "vault-url", "file"); err != nil {
+		return err
+	}
+	entries, err := readImportEntries(importFile, importNameTransform)
+	if err != nil {
+		return err
+	}
+
+	setupDebugLogging()
+	debugLog("Importing %d secret(s) from %s into %s (dry run: %t)", len(entries), importFile, vaultURL, importDryRun)
+
+	ctx := context.Background()
+	clients := newVaultClients()
+	names, disabled, err := selectSecrets(ctx, clients, vaultURL, listFilter{})
+	if err != nil {
+		return err
+	}
+	existing := map[string]bool{}
+	for _, name := range slices.Concat(names, disabled) {
+		existing[strings.ToLower(name)] = true
+	}
+
+	for i := range entries {
+		entry := &entries[i]
+		// Secret names are case-insensitive
+		switch {
+		case !existing[strings.ToLower(entry.name)]:
+			entry.action = actionCreate
+		case importSkipExisting:
+			entry.action = actionSkip
+		default:
+			entry.action = actionUpdate
+		}
+	}
+	if !importDryRun {
+		client, err := clients.client(vaultURL)
+		if err != nil {
+			return err
+		}
+		for i, err := range writeImportEntries(ctx, client, entries) {
+			entries[i].err = err
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	counts := map[writeAction]int{}
+	summary := &retrievalError{total: len(entries), action: "import"}
+	for _, entry := range entries {
+		if entry.err != nil {
+			reportError(os.Stderr, entry.err)
+			summary.failed = append(summary.failed, entry.err)
+			continue
+		}
+		counts[entry.action]++
+		if entry.key != entry.name {
+			fmt.Fprintf(out, "%-9s %s (from %s)\n", entry.action, entry.name, entry.key)
+		} else {
+			fmt.Fprintf(out, "%-9s %s\n", entry.action, entry.name)
+		}
+	}
+
+	verbs := []string{"created", "updated"}
+	if importDryRun {
+		verbs = []string{"to create", "to update"}
+	}
+	fmt.Fprintf(out, "%d %s, %d %s, %d skipped", counts[actionCreate], verbs[0], counts[actionUpdate], verbs[1], counts[actionSkip])
+	if len(summary.failed) > 0 {
+		fmt.Fprintf(out, ", %d failed", len(summary.failed))
+	}
+	if importDryRun {
+		fmt.Fprint(out, " (dry run, nothing was written)")
+	}
+	fmt.Fprintln(out)
+
+	if len(summary.failed) > 0 {
+		return summary
+	}
+	return nil
+}
+
+// readImportEntries reads the file to import and converts its keys to secret
+// names. Every name is checked before anything is written.
+func readImportEntries(path, transform string) ([]importEntry, error) {
+	if !isNameTransform(transform) {
+		return nil, usageErrorf("unsupported name transform: %s (supported: %s)", transform, strings.Join(nameTransforms, ", "))
+	}
+	secrets, _, err := readSecretsFile(path)
+	if err != nil {
+		return nil, withClass(classUsage, err)
+	}
+	if len(secrets) == 0 {
+		return nil, usageErrorf("'%s' contains no secrets", path)
+	}
+
+	entries := make([]importEntry, 0, len(secrets))
+	keys := map[string]string{}
+	for _, secret := range secrets {
+		name := transformName(secret.key, transform)
+		if !secretNamePattern.MatchString(name) {
+			return nil, usageErrorf("key '%s' does not make a valid secret name ('%s'); names may only contain letters, digits and hyphens", secret.key, name)
+		}
+		if other, ok := keys[strings.ToLower(name)]; ok {
+			return nil, usageErrorf("keys '%s' and '%s' both map to secret '%s'", other, secret.key, name)
+		}
+		keys[strings.ToLower(name)] = secret.key
+		entries = append(entries, importEntry{key: secret.key, name: name, value: secret.value})
+	}
+	return entries, nil
+}
+
+func isNameTransform(transform string) bool {
+	for _, supported := range nameTransforms {
+		if transform == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// transformName converts a file key into a secret name.
+func transformName(key, transform string) string {
+	switch transform {
+	case "kebab":
+		key = strings.ToLower(key)
+		fallthrough
+	case "hyphen":
+		return strings.NewReplacer("_", "-", ".", "-").Replace(key)
+	default:
+		return key
+	}
+}
+
+// writeImportEntries sets every entry that is not skipped, with at most
+// --concurrency requests in flight, and returns their errors in the order of
+// entries.
+func writeImportEntries(ctx context.Context, client *azsecrets.Client, entries []importEntry) []error {
+	var tags map[string]*string
+	if len(importTags) > 0 {
+		tags = make(map[string]*string, len(importTags))
+		for key, tag := range importTags {
+			tags[key] = &tag
+		}
+	}
+
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.name
+	}
+	return forEachSecret(names, func(i int, name string) error {
+		entry := entries[i]
+		if entry.action == actionSkip {
+			return nil
+		}
+		debugLog("Setting secret '%s' from key '%s'", name, entry.key)
+		parameters := azsecrets.SetSecretParameters{Value: &entry.value, Tags: tags}
+		if _, err := client.SetSecret(ctx, name, parameters, nil); err != nil {
+			debugLog("Failed to set secret '%s': %v", name, err)
+			return fmt.Errorf("failed to set secret '%s': %w", name, err)
+		}
+		forgetChangedSecret(vaultURL, name)
+		return nil
+	})
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeImportFile writes a file to import into a temporary directory and
+// returns its path.
+func writeImportFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestImportSecrets(t *testing.T) {
+	cleanTestEnvironment(t)
+
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		args     []string
+		expected string
+		values   map[string]string // vault values afterwards; "" means absent
+	}{
+		{
+			name:    "dotenv with kebab names",
+			file:    ".env",
+			content: "DB_PASSWORD=hunter2\nAPI_KEY='new key'\n",
+			expected: "create    db-password (from DB_PASSWORD)\n" +
+				"update    api-key (from API_KEY)\n" +
+				"1 created, 1 updated, 0 skipped\n",
+			values: map[string]string{"db-password": "hunter2", "api-key": "new key"},
+		},
+		{
+			name:     "skip existing",
+			file:     ".env",
+			content:  "DB_PASSWORD=hunter2\nAPI_KEY=new\n",
+			args:     []string{"--skip-existing"},
+			expected: "create    db-password (from DB_PASSWORD)\nskip      api-key (from API_KEY)\n1 created, 0 updated, 1 skipped\n",
+			values:   map[string]string{"db-password": "hunter2", "api-key": "old"},
+		},
+		{
+			name:     "dry run writes nothing",
+			file:     ".env",
+			content:  "DB_PASSWORD=hunter2\nAPI_KEY=new\n",
+			args:     []string{"--dry-run"},
+			expected: "create    db-password (from DB_PASSWORD)\nupdate    api-key (from API_KEY)\n1 to create, 1 to update, 0 skipped (dry run, nothing was written)\n",
+			values:   map[string]string{"db-password": "", "api-key": "old"},
+		},
+		{
+			name:     "json with hyphen names",
+			file:     "secrets.json",
+			content:  `{"Service_Token": "t", "tls.cert": "c"}`,
+			args:     []string{"--name-transform", "hyphen"},
+			expected: "create    Service-Token (from Service_Token)\ncreate    tls-cert (from tls.cert)\n2 created, 0 updated, 0 skipped\n",
+			values:   map[string]string{"Service-Token": "t", "tls-cert": "c"},
+		},
+		{
+			name:     "yaml with names kept",
+			file:     "secrets.yaml",
+			content:  "api-key: from-yaml\nport: 8080\n",
+			args:     []string{"--name-transform", "none", "--concurrency", "1"},
+			expected: "update    api-key\ncreate    port\n1 created, 1 updated, 0 skipped\n",
+			values:   map[string]string{"api-key": "from-yaml", "port": "8080"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vault := newFakeVault("importer")
+			vault.add("api-key", "old")
+			installFakeVaults(t, vault)
+
+			args := append([]string{"-v", vault.url(), "import", "--file", writeImportFile(t, tt.file, tt.content)}, tt.args...)
+			out, err := executeCommand(t, args...)
+			if err != nil {
+				t.Fatalf("executeCommand() unexpected error: %v", err)
+			}
+			if out != tt.expected {
+				t.Errorf("executeCommand() =\n%s\nwant:\n%s", out, tt.expected)
+			}
+			for name, want := range tt.values {
+				secret, ok := vault.latest(name)
+				if want == "" {
+					if ok {
+						t.Errorf("secret '%s' exists; want it absent", name)
+					}
+				} else if !ok || *secret.Value != want {
+					t.Errorf("secret '%s' = %v; want %q", name, secret.Value, want)
+				}
+			}
+		})
+	}
+}
+
+func TestImportTags(t *testing.T) {
+	cleanTestEnvironment(t)
+	vault := newFakeVault("import-tags")
+	installFakeVaults(t, vault)
+
+	path := writeImportFile(t, ".env", "A=1\n")
+	if _, err := executeCommand(t, "-v", vault.url(), "import", "-f", path, "--tags", "source=env-file,team=payments"); err != nil {
+		t.Fatalf("executeCommand() unexpected error: %v", err)
+	}
+	secret, _ := vault.latest("a")
+	if len(secret.Tags) != 2 || *secret.Tags["source"] != "env-file" || *secret.Tags["team"] != "payments" {
+		t.Errorf("imported secret tags = %v; want source and team", secret.Tags)
+	}
+}
+
+func TestImportFailures(t *testing.T) {
+	cleanTestEnvironment(t)
+	vault := newFakeVault("import-failures")
+	vault.add("blocked", "old")
+	installFakeVaults(t, vault)
+	if _, err := executeCommand(t, "-v", vault.url(), "delete", "blocked", "--yes"); err != nil {
+		t.Fatalf("delete: unexpected error: %v", err)
+	}
+
+	path := writeImportFile(t, ".env", "BLOCKED=x\nFINE=y\n")
+	out, err := executeCommand(t, "-v", vault.url(), "import", "-f", path)
+	if err == nil || !strings.Contains(err.Error(), "failed to import 1 of 2 secrets") {
+		t.Errorf("executeCommand() error = %v; want 1 of 2 failed", err)
+	}
+	if want := "create    fine (from FINE)\n1 created, 0 updated, 0 skipped, 1 failed\n"; out != want {
+		t.Errorf("executeCommand() =\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestImportUsageErrors(t *testing.T) {
+	cleanTestEnvironment(t)
+	vault := newFakeVault("import-usage")
+	installFakeVaults(t, vault)
+
+	tests := []struct {
+		name          string
+		content       string
+		args          []string
+		errorContains string
+	}{
+		{"no file", "", []string{"-v", vault.url(), "import"}, `required flag(s) "file" not set`},
+		{"invalid name", "A B=1\n", nil, "key 'A B' does not make a valid secret name ('a b')"},
+		{"invalid name kept", "DB_PASSWORD=1\n", []string{"--name-transform", "none"}, "key 'DB_PASSWORD' does not make a valid secret name"},
+		{"duplicate names", "DB_PASSWORD=1\ndb.password=2\n", nil, "keys 'DB_PASSWORD' and 'db.password' both map to secret 'db-password'"},
+		{"empty file", "# nothing\n", nil, "contains no secrets"},
+		{"unsupported transform", "A=1\n", []string{"--name-transform", "snake"}, "unsupported name transform: snake"},
+		{"unparsable file", "A='x\n", nil, "unterminated quoted value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.content != "" {
+				args = append([]string{"-v", vault.url(), "import", "-f", writeImportFile(t, ".env", tt.content)}, tt.args...)
+			}
+			_, err := executeCommand(t, args...)
+			if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+				t.Errorf("executeCommand() error = %v; should contain %s", err, tt.errorContains)
+			}
+			if err != nil && classifyError(err).Code != classUsage.code {
+				t.Errorf("executeCommand() error %v is not a usage error", err)
+			}
+		})
+	}
+	if len(vault.secrets) != 0 {
+		t.Errorf("vault has %d secrets after failed imports; want none", len(vault.secrets))
+	}
+}
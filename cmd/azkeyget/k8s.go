@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/base64"
+	"io"
+	"regexp"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	k8sName        string
+	k8sNamespace   string
+	k8sType        string
+	k8sLabels      map[string]string
+	k8sAnnotations map[string]string
+	k8sStringData  bool
+)
+
+var (
+	// k8sNamePattern matches DNS subdomain names, as required for Secret names.
+	k8sNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+	// k8sNamespacePattern matches DNS labels, as required for namespaces.
+	k8sNamespacePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+)
+
+// k8sSecret is a Kubernetes v1 Secret manifest.
+type k8sSecret struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   k8sMetadata       `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	Data       map[string]string `yaml:"data,omitempty"`
+	StringData map[string]string `yaml:"stringData,omitempty"`
+}
+
+type k8sMetadata struct {
+	Name        string            `yaml:"name"`
+	Namespace   string            `yaml:"namespace,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// addK8sSecretFlags adds the flags of the k8s-secret output format.
+func addK8sSecretFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&k8sName, "k8s-name", getEnvOrDefault("AZKEYGET_K8S_NAME", ""), "Name of the Secret written by --output k8s-secret (env: AZKEYGET_K8S_NAME)")
+	cmd.Flags().StringVar(&k8sNamespace, "k8s-namespace", getEnvOrDefault("AZKEYGET_K8S_NAMESPACE", ""), "Namespace of the Secret written by --output k8s-secret (env: AZKEYGET_K8S_NAMESPACE)")
+	cmd.Flags().StringVar(&k8sType, "k8s-type", getEnvOrDefault("AZKEYGET_K8S_TYPE", "Opaque"), "Type of the Secret written by --output k8s-secret (env: AZKEYGET_K8S_TYPE)")
+	cmd.Flags().StringToStringVar(&k8sLabels, "k8s-label", nil, "Label of the Secret as key=value, repeatable or comma-separated")
+	cmd.Flags().StringToStringVar(&k8sAnnotations, "k8s-annotation", nil, "Annotation of the Secret as key=value, repeatable")
+	cmd.Flags().BoolVar(&k8sStringData, "k8s-string-data", false, "Write values as plain stringData instead of base64-encoded data")
+}
+
+// validateK8sSecretFlags checks the k8s-secret flags when format is k8s-secret.
+func validateK8sSecretFlags(format string) error {
+	if format != "k8s-secret" {
+		return nil
+	}
+	if k8sName == "" {
+		return usageErrorf("--output k8s-secret requires --k8s-name")
+	}
+	if len(k8sName) > 253 || !k8sNamePattern.MatchString(k8sName) {
+		return usageErrorf("invalid --k8s-name '%s': expected a lowercase DNS subdomain name", k8sName)
+	}
+	if k8sNamespace != "" && (len(k8sNamespace) > 63 || !k8sNamespacePattern.MatchString(k8sNamespace)) {
+		return usageErrorf("invalid --k8s-namespace '%s': expected a lowercase DNS label", k8sNamespace)
+	}
+	if k8sType == "" {
+		return usageErrorf("--k8s-type cannot be empty")
+	}
+	return nil
+}
+
+// writeK8sSecret writes a Secret manifest holding every output, keyed by the
+// name set in a manifest or else the secret name. Values are base64-encoded
+// data, or stringData with --k8s-string-data.
+func writeK8sSecret(w io.Writer, outputs []secretOutput) error {
+	secret := k8sSecret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata: k8sMetadata{
+			Name:        k8sName,
+			Namespace:   k8sNamespace,
+			Labels:      k8sLabels,
+			Annotations: k8sAnnotations,
+		},
+		Type: k8sType,
+	}
+	values := make(map[string]string, len(outputs))
+	for _, output := range outputs {
+		key := output.Name
+		if output.Env != "" {
+			key = output.Env
+		}
+		if k8sStringData {
+			values[key] = output.Value
+		} else {
+			values[key] = base64.StdEncoding.EncodeToString([]byte(output.Value))
+		}
+	}
+	if k8sStringData {
+		secret.StringData = values
+	} else {
+		secret.Data = values
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	return encoder.Encode(secret)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestK8sSecretOutput(t *testing.T) {
+	cleanTestEnvironment(t)
+	vault := newFakeVault("k8s")
+	vault.add("db-password", "hunter2")
+	vault.add("tls-key", "-----BEGIN KEY-----\nabc\n")
+	installFakeVaults(t, vault)
+
+	base := []string{"-v", vault.url(), "-s", "db-password,tls-key", "-o", "k8s-secret", "--k8s-name", "app-secrets"}
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			name: "data",
+			args: base,
+			expected: `apiVersion: v1
+kind: Secret
+metadata:
+  name: app-secrets
+type: Opaque
+data:
+  db-password: aHVudGVyMg==
+  tls-key: LS0tLS1CRUdJTiBLRVktLS0tLQphYmMK
+`,
+		},
+		{
+			name: "string data with metadata",
+			args: append(base, "--k8s-namespace", "prod", "--k8s-type", "kubernetes.io/tls",
+				"--k8s-label", "app=web,tier=backend", "--k8s-annotation", "owner=platform", "--k8s-string-data"),
+			expected: `apiVersion: v1
+kind: Secret
+metadata:
+  name: app-secrets
+  namespace: prod
+  labels:
+    app: web
+    tier: backend
+  annotations:
+    owner: platform
+type: kubernetes.io/tls
+stringData:
+  db-password: hunter2
+  tls-key: |
+    -----BEGIN KEY-----
+    abc
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := executeCommand(t, tt.args...)
+			if err != nil {
+				t.Fatalf("executeCommand() unexpected error: %v", err)
+			}
+			if out != tt.expected {
+				t.Errorf("output =\n%s\nwant\n%s", out, tt.expected)
+			}
+			var secret k8sSecret
+			if err := yaml.Unmarshal([]byte(out), &secret); err != nil {
+				t.Errorf("output is not valid YAML: %v", err)
+			}
+		})
+	}
+}
+
+func TestK8sSecretOutputErrors(t *testing.T) {
+	cleanTestEnvironment(t)
+	vault := newFakeVault("k8serr")
+	vault.add("db-password", "hunter2")
+	installFakeVaults(t, vault)
+
+	tests := []struct {
+		name          string
+		args          []string
+		errorContains string
+		code          int
+	}{
+		{name: "missing name", args: []string{"-o", "k8s-secret"}, errorContains: "requires --k8s-name", code: classUsage.code},
+		{name: "invalid name", args: []string{"-o", "k8s-secret", "--k8s-name", "App_Secrets"}, errorContains: "invalid --k8s-name", code: classUsage.code},
+		{name: "invalid namespace", args: []string{"-o", "k8s-secret", "--k8s-name", "app", "--k8s-namespace", "prod.eu"}, errorContains: "invalid --k8s-namespace", code: classUsage.code},
+		{name: "empty type", args: []string{"-o", "k8s-secret", "--k8s-name", "app", "--k8s-type", ""}, errorContains: "--k8s-type cannot be empty", code: classUsage.code},
+		{name: "partial secret", args: []string{"-o", "k8s-secret", "--k8s-name", "app", "-s", "missing"}, errorContains: "failed to retrieve 1 of 2 secrets", code: classNotFound.code},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-v", vault.url(), "-s", "db-password"}, tt.args...)
+			out, err := executeCommand(t, args...)
+			if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+				t.Fatalf("error = %v; want it to contain %q", err, tt.errorContains)
+			}
+			if got := classifyError(err).Code; got != tt.code {
+				t.Errorf("exit code = %d; want %d", got, tt.code)
+			}
+			// A Secret missing some keys would remove them when applied
+			if out != "" {
+				t.Errorf("output = %q; want none", out)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/spf13/cobra"
+)
+
+// listFormats lists the values accepted by list --format.
+var listFormats = []string{"table", "json", "csv"}
+
+var (
+	listPrefix         string
+	listRegex          string
+	listTags           map[string]string
+	listEnabledOnly    bool
+	listExpiringWithin string
+	listFormat         string
+)
+
+// secretListing is the structured representation of a listed secret. Listing
+// never reads secret values.
+type secretListing struct {
+	Name        string            `json:"name"`
+	ID          string            `json:"id"`
+	ContentType string            `json:"contentType,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Enabled     *bool             `json:"enabled,omitempty"`
+	Created     *time.Time        `json:"created,omitempty"`
+	Updated     *time.Time        `json:"updated,omitempty"`
+	Expires     *time.Time        `json:"expires,omitempty"`
+}
+
+// listFilter selects the secrets printed by list.
+type listFilter struct {
+	prefix      string
+	pattern     *regexp.Regexp
+	tags        map[string]string
+	enabledOnly bool
+	expiresBy   time.Time // zero when --expiring-within is not set
+}
+
+func newListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the secrets in a vault",
+		Long: `List the secrets in --vault-url with their content type, tags, enabled state and
+created, updated and expiry times. Secret values are not read.
+
+All given filters must match. --expiring-within selects secrets that expire
+within the given duration, such as 30d or 12h, including secrets that have
+already expired.`,
+		Args: usageArgs(cobra.NoArgs),
+		RunE: listSecrets,
+	}
+
+	cmd.Flags().StringVar(&listPrefix, "prefix", "", "Only list secrets whose name starts with this prefix")
+	cmd.Flags().StringVar(&listRegex, "regex", "", "Only list secrets whose name matches this regular expression")
+	cmd.Flags().StringToStringVar(&listTags, "tag", nil, "Only list secrets with this tag as key=value, repeatable")
+	cmd.Flags().BoolVar(&listEnabledOnly, "enabled-only", false, "Only list enabled secrets")
+	cmd.Flags().StringVar(&listExpiringWithin, "expiring-within", "", "Only list secrets that expire within this duration, e.g. 30d")
+	cmd.Flags().StringVar(&listFormat, "format", "table", "Output format: table, json, csv")
+
+	return cmd
+}
+
+func listSecrets(cmd *cobra.Command, _ []string) error {
+	if err := requireFlags(cmd, "vault-url"); err != nil {
+		return err
+	}
+	filter, err := newListFilter(time.Now())
+	if err != nil {
+		return err
+	}
+	if err := validateListFormat(listFormat); err != nil {
+		return err
+	}
+
+	setupDebugLogging()
+	debugLog("Listing secrets in %s", vaultURL)
+
+	client, err := newKeyVaultClient(vaultURL)
+	if err != nil {
+		return err
+	}
+
+	var listings []secretListing
+	pager := client.NewListSecretPropertiesPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(context.Background())
+		if err != nil {
+			debugLog("Failed to list secrets: %v", err)
+			return fmt.Errorf("failed to list secrets: %w", err)
+		}
+		for _, props := range page.Value {
+			if filter.matches(props) {
+				listings = append(listings, newSecretListing(props))
+			}
+		}
+	}
+	debugLog("Found %d matching secret(s)", len(listings))
+
+	sort.SliceStable(listings, func(i, j int) bool {
+		return listings[i].Name < listings[j].Name
+	})
+	return writeListings(cmd.OutOrStdout(), listFormat, listings)
+}
+
+// validateListFormat rejects unknown list --format values.
+func validateListFormat(format string) error {
+	for _, supported := range listFormats {
+		if format == supported {
+			return nil
+		}
+	}
+	return usageErrorf("unsupported list format: %s (supported: %s)", format, strings.Join(listFormats, ", "))
+}
+
+// newListFilter builds the filter from the list flags.
+func newListFilter(now time.Time) (listFilter, error) {
+	filter := listFilter{prefix: listPrefix, tags: listTags, enabledOnly: listEnabledOnly}
+	if listRegex != "" {
+		pattern, err := regexp.Compile(listRegex)
+		if err != nil {
+			return listFilter{}, usageErrorf("invalid --regex: %w", err)
+		}
+		filter.pattern = pattern
+	}
+	if listExpiringWithin != "" {
+		within, err := parseDuration(listExpiringWithin)
+		if err != nil {
+			return listFilter{}, usageErrorf("invalid --expiring-within: %w", err)
+		}
+		filter.expiresBy = now.Add(within)
+	}
+	return filter, nil
+}
+
+// matches reports whether a secret passes every filter.
+func (f listFilter) matches(props *azsecrets.SecretProperties) bool {
+	if props.ID == nil {
+		return false
+	}
+	name := props.ID.Name()
+	if !strings.HasPrefix(name, f.prefix) {
+		return false
+	}
+	if f.pattern != nil && !f.pattern.MatchString(name) {
+		return false
+	}
+	for key, want := range f.tags {
+		if value := props.Tags[key]; value == nil || *value != want {
+			return false
+		}
+	}
+	if f.enabledOnly && (props.Attributes == nil || props.Attributes.Enabled == nil || !*props.Attributes.Enabled) {
+		return false
+	}
+	if !f.expiresBy.IsZero() {
+		expiry := attributeTime(props.Attributes, expires)
+		if expiry.IsZero() || expiry.After(f.expiresBy) {
+			return false
+		}
+	}
+	return true
+}
+
+// newSecretListing flattens the properties of a listed secret.
+func newSecretListing(props *azsecrets.SecretProperties) secretListing {
+	listing := secretListing{
+		Name: props.ID.Name(),
+		ID:   string(*props.ID),
+	}
+	if props.ContentType != nil {
+		listing.ContentType = *props.ContentType
+	}
+	if len(props.Tags) > 0 {
+		listing.Tags = make(map[string]string, len(props.Tags))
+		for key, value := range props.Tags {
+			if value != nil {
+				listing.Tags[key] = *value
+			}
+		}
+	}
+	if attrs := props.Attributes; attrs != nil {
+		listing.Enabled = attrs.Enabled
+		listing.Created = attrs.Created
+		listing.Updated = attrs.Updated
+		listing.Expires = attrs.Expires
+	}
+	return listing
+}
+
+// writeListings writes the listed secrets in format.
+func writeListings(w io.Writer, format string, listings []secretListing) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tCONTENT TYPE\tTAGS\tENABLED\tCREATED\tUPDATED\tEXPIRES")
+		for _, listing := range listings {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				listing.Name,
+				orDash(listing.ContentType),
+				orDash(formatTags(listing.Tags)),
+				formatEnabled(listing.Enabled),
+				formatDate(listing.Created),
+				formatDate(listing.Updated),
+				formatDate(listing.Expires),
+			)
+		}
+		return tw.Flush()
+	case "json":
+		if listings == nil {
+			listings = []secretListing{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(listings)
+	case "csv":
+		// Empty cells rather than "-" keep the columns easy to process
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"name", "contentType", "tags", "enabled", "created", "updated", "expires"})
+		for _, listing := range listings {
+			enabled := ""
+			if listing.Enabled != nil {
+				enabled = fmt.Sprintf("%t", *listing.Enabled)
+			}
+			cw.Write([]string{
+				listing.Name,
+				listing.ContentType,
+				formatTags(listing.Tags),
+				enabled,
+				csvDate(listing.Created),
+				csvDate(listing.Updated),
+				csvDate(listing.Expires),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return validateListFormat(format)
+	}
+}
+
+// csvDate formats an optional timestamp as RFC 3339, or empty when unset.
+func csvDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatDate(t)
+}
+
+// formatTags formats tags as comma-separated key=value pairs sorted by key.
+func formatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for key, value := range tags {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// formatEnabled formats an optional enabled flag, or "-" when unset.
+func formatEnabled(enabled *bool) string {
+	if enabled == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%t", *enabled)
+}
+
+// orDash returns s, or "-" when it is empty, for table cells.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+)
+
+func TestListSecrets(t *testing.T) {
+	cleanTestEnvironment(t)
+	vault := newFakeVault("catalog")
+	soon := time.Now().Add(10 * 24 * time.Hour)
+	later := time.Now().Add(100 * 24 * time.Hour)
+	vault.put("app-db-password", azsecrets.Secret{
+		Value: to.Ptr("a"),
+		Tags:  map[string]*string{"env": to.Ptr("prod"), "team": to.Ptr("payments")},
+		Attributes: &azsecrets.SecretAttributes{
+			Expires: &soon,
+		},
+	})
+	vault.put("app-api-key", azsecrets.Secret{
+		Value:       to.Ptr("b"),
+		ContentType: to.Ptr("text/plain"),
+		Tags:        map[string]*string{"env": to.Ptr("dev")},
+		Attributes:  &azsecrets.SecretAttributes{Expires: &later},
+	})
+	vault.put("legacy-token", azsecrets.Secret{
+		Value:      to.Ptr("c"),
+		Attributes: &azsecrets.SecretAttributes{Enabled: to.Ptr(false)},
+	})
+	installFakeVaults(t, vault)
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"all secrets sorted by name", nil, []string{"app-api-key", "app-db-password", "legacy-token"}},
+		{"prefix", []string{"--prefix", "app-"}, []string{"app-api-key", "app-db-password"}},
+		{"regex", []string{"--regex", "(key|token)$"}, []string{"app-api-key", "legacy-token"}},
+		{"tag", []string{"--tag", "env=prod"}, []string{"app-db-password"}},
+		{"every tag must match", []string{"--tag", "env=prod", "--tag", "team=search"}, nil},
+		{"enabled only", []string{"--enabled-only"}, []string{"app-api-key", "app-db-password"}},
+		{"expiring within", []string{"--expiring-within", "30d"}, []string{"app-db-password"}},
+		{"filters combine", []string{"--prefix", "app-", "--expiring-within", "365d", "--tag", "env=dev"}, []string{"app-api-key"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-v", vault.url(), "list", "--format", "csv"}, tt.args...)
+			out, err := executeCommand(t, args...)
+			if err != nil {
+				t.Fatalf("executeCommand() unexpected error: %v", err)
+			}
+
+			var names []string
+			for _, line := range strings.Split(strings.TrimSpace(out), "\n")[1:] {
+				names = append(names, strings.SplitN(line, ",", 2)[0])
+			}
+			if strings.Join(names, " ") != strings.Join(tt.expected, " ") {
+				t.Errorf("list %v = %v; want %v", tt.args, names, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWriteListings(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	listings := []secretListing{
+		{
+			Name:        "alpha",
+			ID:          "https://example.vault.azure.net/secrets/alpha",
+			ContentType: "text/plain",
+			Tags:        map[string]string{"team": "a", "env": "prod"},
+			Enabled:     to.Ptr(true),
+			Created:     &created,
+		},
+		{
+			Name: "beta",
+			ID:   "https://example.vault.azure.net/secrets/beta",
+		},
+	}
+
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format: "table",
+			expected: "NAME   CONTENT TYPE  TAGS             ENABLED  CREATED               UPDATED  EXPIRES\n" +
+				"alpha  text/plain    env=prod,team=a  true     2024-01-02T03:04:05Z  -        -\n" +
+				"beta   -             -                -        -                     -        -\n",
+		},
+		{
+			format: "csv",
+			expected: "name,contentType,tags,enabled,created,updated,expires\n" +
+				"alpha,text/plain,\"env=prod,team=a\",true,2024-01-02T03:04:05Z,,\n" +
+				"beta,,,,,,\n",
+		},
+		{
+			format: "json",
+			expected: `[
+  {
+    "name": "alpha",
+    "id": "https://example.vault.azure.net/secrets/alpha",
+    "contentType": "text/plain",
+    "tags": {
+      "env": "prod",
+      "team": "a"
+    },
+    "enabled": true,
+    "created": "2024-01-02T03:04:05Z"
+  },
+  {
+    "name": "beta",
+    "id": "https://example.vault.azure.net/secrets/beta"
+  }
+]
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := writeListings(&out, tt.format, listings); err != nil {
+				t.Fatalf("writeListings() unexpected error: %v", err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("writeListings() =\n%s\nwant:\n%s", out.String(), tt.expected)
+			}
+		})
+	}
+
+	var out bytes.Buffer
+	if err := writeListings(&out, "json", nil); err != nil || out.String() != "[]\n" {
+		t.Errorf("writeListings() with no secrets = %q, %v; want an empty list", out.String(), err)
+	}
+}
+
+func TestListUsageErrors(t *testing.T) {
+	cleanTestEnvironment(t)
+
+	tests := []struct {
+		name          string
+		args          []string
+		errorContains string
+	}{
+		{"no vault url", []string{"list"}, `required flag(s) "vault-url" not set`},
+		{"invalid regex", []string{"-v", "https://v.vault.azure.net/", "list", "--regex", "("}, "invalid --regex"},
+		{"invalid duration", []string{"-v", "https://v.vault.azure.net/", "list", "--expiring-within", "soon"}, "invalid --expiring-within"},
+		{"invalid tag", []string{"-v", "https://v.vault.azure.net/", "list", "--tag", "env"}, "invalid argument"},
+		{"unsupported format", []string{"-v", "https://v.vault.azure.net/", "list", "--format", "yaml"}, "unsupported list format: yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := executeCommand(t, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+				t.Errorf("executeCommand() error = %v; should contain %s", err, tt.errorContains)
+			}
+			if err != nil && classifyError(err).Code != classUsage.code {
+				t.Errorf("executeCommand() error %v is not a usage error", err)
+			}
+		})
+	}
+}
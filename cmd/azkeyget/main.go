@@ -3,14 +3,22 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 // Build-time variables set by GoReleaser
@@ -21,94 +29,388 @@ var (
 )
 
 var (
-	vaultURL       string
-	secretName     string
-	authMethod     string
-	clientID       string
-	clientSecret   string
-	tenantID       string
-	userAssignedID string
-	debug          bool
+	vaultURL                  string
+	secretNames               []string
+	secretVersion             string
+	outputFormat              string
+	authMethod                string
+	clientID                  string
+	clientSecret              string
+	clientSecretFile          string
+	clientSecretStdin         bool
+	allowInsecurePerms        bool
+	tenantID                  string
+	userAssignedID            string
+	federatedTokenFile        string
+	clientCertificate         string
+	clientCertificatePassword string
+	sendCertificateChain      bool
+	authorityHost             string
+	concurrency               int
+	failFast                  bool
+	manifestFile              string
+	errorFormat               string
+	configFile                string
+	profileName               string
+	debug                     bool
+)
+
+// Test seams: tests replace these to use fake Azure endpoints and input.
+var (
+	newCredential       = createCredential
+	clientOptions       *azsecrets.ClientOptions
+	credentialTransport policy.Transporter
+	stdin               io.Reader = os.Stdin
 )
 
 func main() {
-	rootCmd := &cobra.Command{
-		Use:     "azkeyget",
-		Short:   "Get secrets from Azure Key Vault",
-		Long:    "A CLI tool to retrieve secrets from Azure Key Vault with support for multiple authentication methods",
-		Version: fmt.Sprintf("%s (commit: %s, built: %s)", version, commit, date),
-		RunE:    getSecret,
+	cmd, err := newRootCmd().ExecuteC()
+	if err == nil {
+		return
+	}
+	var exitErr *exitCodeError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.code)
 	}
 
-	rootCmd.Flags().StringVarP(&vaultURL, "vault-url", "v", getEnvOrDefault("AZURE_KEYVAULT_URL", ""), "Azure Key Vault URL (required, env: AZURE_KEYVAULT_URL)")
-	rootCmd.Flags().StringVarP(&secretName, "secret", "s", getEnvOrDefault("AZURE_KEYVAULT_SECRET_NAME", ""), "Secret name to retrieve (required, env: AZURE_KEYVAULT_SECRET_NAME)")
-	rootCmd.Flags().StringVarP(&authMethod, "auth", "a", getEnvOrDefault("AZURE_AUTH_METHOD", "default"), "Authentication method: default, system-mi, user-mi, service-principal (env: AZURE_AUTH_METHOD)")
-	rootCmd.Flags().StringVar(&clientID, "client-id", getEnvOrDefault("AZURE_CLIENT_ID", ""), "Client ID for service principal or user-assigned managed identity (env: AZURE_CLIENT_ID)")
-	rootCmd.Flags().StringVar(&clientSecret, "client-secret", getEnvOrDefault("AZURE_CLIENT_SECRET", ""), "Client secret for service principal authentication (env: AZURE_CLIENT_SECRET)")
-	rootCmd.Flags().StringVar(&tenantID, "tenant-id", getEnvOrDefault("AZURE_TENANT_ID", ""), "Tenant ID for service principal authentication (env: AZURE_TENANT_ID)")
-	rootCmd.Flags().StringVar(&userAssignedID, "user-assigned-id", getEnvOrDefault("AZURE_USER_ASSIGNED_ID", ""), "User-assigned managed identity client ID (env: AZURE_USER_ASSIGNED_ID)")
-	rootCmd.Flags().BoolVar(&debug, "debug", getEnvOrDefaultBool("AZURE_DEBUG", false), "Enable debug logging (env: AZURE_DEBUG)")
-
-	if err := rootCmd.MarkFlagRequired("vault-url"); err != nil {
-		fmt.Fprintf(os.Stderr, "Error marking vault-url as required: %v\n", err)
-		os.Exit(1)
+	reportError(os.Stderr, err)
+	code := classifyError(err).Code
+	if code == classUsage.code && errorFormat != "json" {
+		fmt.Fprintf(os.Stderr, "Run '%s --help' for usage.\n", cmd.CommandPath())
 	}
-	if err := rootCmd.MarkFlagRequired("secret"); err != nil {
-		fmt.Fprintf(os.Stderr, "Error marking secret as required: %v\n", err)
-		os.Exit(1)
+	os.Exit(code)
+}
+
+// exitCodeError makes azkeyget exit with code without printing an error, for
+// example to pass on the exit status of a child process. Other errors exit
+// with the code of their failure class; see classifyError.
+type exitCodeError struct {
+	code int
+}
+
+func (e *exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// newRootCmd builds the azkeyget command tree. Connection and authentication
+// flags are persistent so that every subcommand shares them.
+func newRootCmd() *cobra.Command {
+	rootCmd := &cobra.Command{
+		Use:   "azkeyget [secret-reference...]",
+		Short: "Get secrets from Azure Key Vault",
+		Long: `A CLI tool to retrieve secrets from Azure Key Vault with support for multiple authentication methods.
+
+Secrets are selected with --secret and --vault-url, or given as full references:
+
+  https://myvault.vault.azure.net/secrets/name[/version]
+  @Microsoft.KeyVault(SecretUri=https://myvault.vault.azure.net/secrets/name[/version])
+  @Microsoft.KeyVault(VaultName=myvault;SecretName=name[;SecretVersion=version])`,
+		Args: cobra.ArbitraryArgs,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			if err := validateErrorFormat(errorFormat); err != nil {
+				return err
+			}
+			setupDebugLogging()
+			return applyProfile(cmd)
+		},
+		RunE: getSecret,
+		// main reports errors so that they follow --error-format
+		SilenceErrors: true,
+		SilenceUsage:  true,
 	}
+	rootCmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return &classifiedError{class: classUsage, err: err}
+	})
+
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", getEnvOrDefault("AZKEYGET_CONFIG", ""), "Configuration file (default: .azkeyget.yaml in the working directory or a parent, then ~/.config/azkeyget/config.yaml) (env: AZKEYGET_CONFIG)")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", getEnvOrDefault("AZKEYGET_PROFILE", ""), "Configuration profile supplying defaults for the vault URL, authentication and output (env: AZKEYGET_PROFILE)")
+	rootCmd.PersistentFlags().StringVarP(&vaultURL, "vault-url", "v", getEnvOrDefault("AZURE_KEYVAULT_URL", ""), "Azure Key Vault URL (required, env: AZURE_KEYVAULT_URL)")
+	rootCmd.PersistentFlags().StringVarP(&authMethod, "auth", "a", getEnvOrDefault("AZURE_AUTH_METHOD", "default"), "Authentication method: default, system-mi, user-mi, service-principal, service-principal-cert, workload-identity (env: AZURE_AUTH_METHOD)")
+	rootCmd.PersistentFlags().StringVar(&clientID, "client-id", getEnvOrDefault("AZURE_CLIENT_ID", ""), "Client ID for service principal or user-assigned managed identity (env: AZURE_CLIENT_ID)")
+	rootCmd.PersistentFlags().StringVar(&clientSecret, "client-secret", getEnvOrDefault("AZURE_CLIENT_SECRET", ""), "Client secret for service principal authentication (env: AZURE_CLIENT_SECRET)")
+	rootCmd.PersistentFlags().StringVar(&clientSecretFile, "client-secret-file", getEnvOrDefault("AZURE_CLIENT_SECRET_FILE", ""), "File containing the client secret for service principal authentication (env: AZURE_CLIENT_SECRET_FILE)")
+	rootCmd.PersistentFlags().BoolVar(&clientSecretStdin, "client-secret-stdin", false, "Read the client secret for service principal authentication from stdin")
+	rootCmd.PersistentFlags().BoolVar(&allowInsecurePerms, "allow-insecure-perms", false, "Allow a client secret file that is readable by all users")
+	rootCmd.PersistentFlags().StringVar(&tenantID, "tenant-id", getEnvOrDefault("AZURE_TENANT_ID", ""), "Tenant ID for service principal authentication (env: AZURE_TENANT_ID)")
+	rootCmd.PersistentFlags().StringVar(&userAssignedID, "user-assigned-id", getEnvOrDefault("AZURE_USER_ASSIGNED_ID", ""), "User-assigned managed identity client ID (env: AZURE_USER_ASSIGNED_ID)")
+	rootCmd.PersistentFlags().StringVar(&clientCertificate, "client-certificate", getEnvOrDefault("AZURE_CLIENT_CERTIFICATE_PATH", ""), "PEM or PFX certificate for service principal certificate authentication (env: AZURE_CLIENT_CERTIFICATE_PATH)")
+	rootCmd.PersistentFlags().StringVar(&clientCertificatePassword, "client-certificate-password", getEnvOrDefault("AZURE_CLIENT_CERTIFICATE_PASSWORD", ""), "Password of the client certificate, if any (env: AZURE_CLIENT_CERTIFICATE_PASSWORD)")
+	rootCmd.PersistentFlags().BoolVar(&sendCertificateChain, "send-certificate-chain", getEnvOrDefaultBool("AZURE_CLIENT_SEND_CERTIFICATE_CHAIN", false), "Send the certificate chain for subject name/issuer authentication (env: AZURE_CLIENT_SEND_CERTIFICATE_CHAIN)")
+	rootCmd.PersistentFlags().StringVar(&federatedTokenFile, "federated-token-file", getEnvOrDefault("AZURE_FEDERATED_TOKEN_FILE", ""), "Federated token file for workload identity authentication (env: AZURE_FEDERATED_TOKEN_FILE)")
+	rootCmd.PersistentFlags().StringVar(&authorityHost, "authority-host", getEnvOrDefault("AZURE_AUTHORITY_HOST", ""), "Microsoft Entra authority host, e.g. for sovereign clouds (env: AZURE_AUTHORITY_HOST)")
+	rootCmd.PersistentFlags().IntVar(&concurrency, "concurrency", getEnvOrDefaultInt("AZURE_KEYVAULT_CONCURRENCY", 4), "Maximum number of secrets retrieved in parallel (env: AZURE_KEYVAULT_CONCURRENCY)")
+	rootCmd.PersistentFlags().StringVar(&cacheTTL, "cache-ttl", getEnvOrDefault("AZKEYGET_CACHE_TTL", ""), "Cache retrieved secrets on disk, encrypted, for this long, e.g. 10m or 1h (default: no caching, env: AZKEYGET_CACHE_TTL)")
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", getEnvOrDefaultBool("AZKEYGET_NO_CACHE", false), "Neither read nor write the secret cache (env: AZKEYGET_NO_CACHE)")
+	rootCmd.PersistentFlags().StringVar(&errorFormat, "error-format", getEnvOrDefault("AZURE_KEYVAULT_ERROR_FORMAT", "text"), "Error output format on stderr: text, json (env: AZURE_KEYVAULT_ERROR_FORMAT)")
+	rootCmd.PersistentFlags().BoolVar(&debug, "debug", getEnvOrDefaultBool("AZURE_DEBUG", false), "Enable debug logging (env: AZURE_DEBUG)")
+
+	rootCmd.Flags().StringSliceVarP(&secretNames, "secret", "s", getEnvOrDefaultSlice("AZURE_KEYVAULT_SECRET_NAME", nil), "Secret name to retrieve, repeatable or comma-separated (required, env: AZURE_KEYVAULT_SECRET_NAME)")
+	rootCmd.Flags().StringVar(&secretVersion, "version", getEnvOrDefault("AZURE_KEYVAULT_SECRET_VERSION", ""), "Secret version to retrieve instead of the latest (env: AZURE_KEYVAULT_SECRET_VERSION)")
+	rootCmd.Flags().StringVarP(&outputFormat, "output", "o", getEnvOrDefault("AZURE_KEYVAULT_OUTPUT", "raw"), "Output format: raw, json, yaml, dotenv, export, k8s-secret (env: AZURE_KEYVAULT_OUTPUT)")
+	rootCmd.Flags().StringVar(&manifestFile, "manifest", getEnvOrDefault("AZURE_KEYVAULT_MANIFEST", ""), "Manifest file listing the secrets to retrieve (env: AZURE_KEYVAULT_MANIFEST)")
+	rootCmd.Flags().BoolVar(&failFast, "fail-fast", getEnvOrDefaultBool("AZURE_KEYVAULT_FAIL_FAST", false), "Stop retrieving secrets after the first failure (env: AZURE_KEYVAULT_FAIL_FAST)")
+	addK8sSecretFlags(rootCmd)
+	addGitHubActionsFlags(rootCmd)
+	addCIFlags(rootCmd)
+
+	rootCmd.AddCommand(newVersionsCmd(), newExecCmd(), newRenderCmd(), newWatchCmd(), newSetCmd(), newListCmd(), newCopyCmd(), newDiffCmd(), newImportCmd(), newBackupCmd(), newRestoreCmd(), newCacheCmd(), newAgentCmd(), newDeleteCmd(), newRecoverCmd(), newPurgeCmd(), newListDeletedCmd(), newVersionCmd())
 
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	return rootCmd
+}
+
+// newVersionCmd prints build information. The root --version flag selects a
+// secret version, so build information has its own subcommand.
+func newVersionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Print azkeyget build information",
+		Args:  usageArgs(cobra.NoArgs),
+		Run: func(cmd *cobra.Command, _ []string) {
+			fmt.Fprintf(cmd.OutOrStdout(), "azkeyget version %s (commit: %s, built: %s)\n", version, commit, date)
+		},
 	}
 }
 
-func getSecret(_ *cobra.Command, _ []string) error {
+func getSecret(cmd *cobra.Command, args []string) error {
+	var spec *manifest
+	var refs []secretRef
+	var err error
+	if manifestFile != "" {
+		if len(args) > 0 || len(secretNames) > 0 || secretVersion != "" {
+			return usageErrorf("--manifest cannot be combined with --secret, --version or secret references")
+		}
+		if spec, err = loadManifest(manifestFile); err != nil {
+			return err
+		}
+		refs = spec.refs
+	} else if refs, err = secretRefs(cmd, args); err != nil {
+		return err
+	}
+	if err := validateOutputFormat(outputFormat); err != nil {
+		return err
+	}
+	if err := validateK8sSecretFlags(outputFormat); err != nil {
+		return err
+	}
+	githubTargets, err := githubFiles()
+	if err != nil {
+		return err
+	}
+	if err := validateCIFlags(); err != nil {
+		return err
+	}
+
 	// Setup debug logging
 	setupDebugLogging()
 
 	debugLog("Starting azkeyget execution")
 	debugLog("Configuration:")
 	debugLog("  Vault URL: %s", vaultURL)
-	debugLog("  Secret Name: %s", secretName)
+	debugLog("  Secret Names: %s", strings.Join(secretNames, ", "))
+	debugLog("  Secret References: %s", strings.Join(args, ", "))
+	debugLog("  Secret Version: %s", secretVersion)
+	debugLog("  Manifest: %s", manifestFile)
 	debugLog("  Auth Method: %s", authMethod)
+	debugLog("  Output Format: %s", outputFormat)
+	debugLog("  Concurrency: %d", concurrency)
+	debugLog("  Fail Fast: %t", failFast)
+	debugLog("  GitHub Actions: %t", githubActions)
+	debugLog("  CI System: %s", ciSystem)
 	debugLog("  Debug Enabled: %t", debug)
+	debugLog("  Cache TTL: %s (disabled: %t)", cacheTTL, noCache)
 
+	clients, err := newRetrievalClients()
+	if err != nil {
+		return err
+	}
 	ctx := context.Background()
 
-	debugLog("Creating credential with method: %s", authMethod)
-	credential, err := createCredential()
+	// Optional manifest entries may be missing, so a manifest is always
+	// retrieved in full; --fail-fast then only limits what is reported
+	results := fetchSecrets(ctx, clients, refs, concurrency, failFast && spec == nil)
+	if spec != nil {
+		results = spec.resolve(results)
+	}
+	resultErr := checkResults(results, failFast)
+	// Applying a Secret without some of its keys would remove them
+	if resultErr != nil && (failFast || outputFormat == "k8s-secret") {
+		return resultErr
+	}
+
+	// Values are masked before they can appear anywhere in the job log. The
+	// masks go to stderr, which the runner reads too, so that stdout can still
+	// be captured by scripts.
+	if githubActions || len(githubTargets) > 0 {
+		writeGitHubMasks(cmd.ErrOrStderr(), results)
+	}
+
+	// Secrets that were retrieved are still written when others failed
+	switch {
+	case len(githubTargets) > 0:
+		for _, path := range githubTargets {
+			if err := appendGitHubFile(path, results); err != nil {
+				return err
+			}
+		}
+	case ciSystem != "":
+		if err := writeCI(cmd.OutOrStdout(), results); err != nil {
+			return err
+		}
+	default:
+		debugLog("Outputting retrieved secrets to stdout")
+		if err := writeSecrets(cmd.OutOrStdout(), outputFormat, results); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+	}
+	if resultErr != nil {
+		return resultErr
+	}
+	debugLog("Operation completed successfully")
+	return nil
+}
+
+// secretRefs combines full secret references given as arguments with the
+// names given by --secret. --vault-url is only required for the latter.
+func secretRefs(cmd *cobra.Command, args []string) ([]secretRef, error) {
+	if len(args) == 0 {
+		if err := requireFlags(cmd, "vault-url", "secret"); err != nil {
+			return nil, err
+		}
+	} else if len(secretNames) > 0 {
+		if err := requireFlags(cmd, "vault-url"); err != nil {
+			return nil, err
+		}
+	}
+	if secretVersion != "" && (len(secretNames) != 1 || len(args) > 0) {
+		return nil, usageErrorf("--version can only be used with a single secret")
+	}
+
+	refs := make([]secretRef, 0, len(args)+len(secretNames))
+	for _, arg := range args {
+		if !isSecretReference(arg) {
+			return nil, usageErrorf("invalid secret reference '%s': expected a secret URI or @Microsoft.KeyVault(...) reference; use --secret for secret names", arg)
+		}
+		ref, err := parseSecretReference(arg)
+		if err != nil {
+			return nil, withClass(classUsage, err)
+		}
+		refs = append(refs, ref)
+	}
+	for _, name := range secretNames {
+		refs = append(refs, secretRef{vault: vaultURL, name: name, version: secretVersion})
+	}
+	return refs, nil
+}
+
+// newKeyVaultClient creates a credential for the configured authentication
+// method and a Key Vault client for vaultURL.
+func newKeyVaultClient(vaultURL string) (*azsecrets.Client, error) {
+	return newVaultClients().client(vaultURL)
+}
+
+// vaultClients creates Key Vault clients on demand, one per vault, sharing a
+// single credential between them. newCredential, when set, replaces the
+// package-level credential factory. Secrets are requested from the agent
+// listening on the agent socket and read through cache when these are set.
+type vaultClients struct {
+	mu            sync.Mutex
+	credential    azcore.TokenCredential
+	clients       map[string]*azsecrets.Client
+	newCredential func() (azcore.TokenCredential, error)
+	agent         string
+	cache         *secretCache
+}
+
+func newVaultClients() *vaultClients {
+	return &vaultClients{clients: map[string]*azsecrets.Client{}}
+}
+
+// newRetrievalClients returns the vault clients of commands that only read
+// secrets. They ask the agent at $AZKEYGET_AGENT_SOCK first, if set, and use
+// the secret cache when --cache-ttl enables it. A cache that cannot be opened
+// is skipped, so that caching never stops secrets from being retrieved.
+func newRetrievalClients() (*vaultClients, error) {
+	clients := newVaultClients()
+	clients.agent = os.Getenv(agentSocketEnv)
+	if noCache || cacheTTL == "" {
+		return clients, nil
+	}
+	ttl, err := parseDuration(cacheTTL)
+	if err != nil || ttl < 0 {
+		return nil, usageErrorf("invalid --cache-ttl '%s': expected a duration such as 10m, 1h or 1d", cacheTTL)
+	}
+	if ttl == 0 {
+		return clients, nil
+	}
+
+	dir, err := cacheDir()
+	if err == nil {
+		clients.cache, err = openSecretCache(dir, ttl)
+	}
 	if err != nil {
-		debugLog("Failed to create credential: %v", err)
-		return fmt.Errorf("failed to create credential: %w", err)
+		debugLog("Secret cache disabled: %v", err)
+	}
+	return clients, nil
+}
+
+// client returns the Key Vault client for vaultURL, creating the credential
+// on first use.
+func (c *vaultClients) client(vaultURL string) (*azsecrets.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if client, ok := c.clients[vaultURL]; ok {
+		return client, nil
+	}
+
+	if c.credential == nil {
+		create := func() (azcore.TokenCredential, error) {
+			return newCredential(flagAuthSettings())
+		}
+		if c.newCredential != nil {
+			create = c.newCredential
+		}
+		credential, err := create()
+		if err != nil {
+			debugLog("Failed to create credential: %v", err)
+			return nil, withClass(classAuth, fmt.Errorf("failed to create credential: %w", err))
+		}
+		debugLog("Successfully created credential")
+		c.credential = authClassifyingCredential{credential}
 	}
-	debugLog("Successfully created credential")
 
 	debugLog("Creating Key Vault client for URL: %s", vaultURL)
-	client, err := azsecrets.NewClient(vaultURL, credential, nil)
+	client, err := azsecrets.NewClient(vaultURL, c.credential, clientOptions)
 	if err != nil {
 		debugLog("Failed to create Key Vault client: %v", err)
-		return fmt.Errorf("failed to create Key Vault client: %w", err)
+		return nil, fmt.Errorf("failed to create Key Vault client: %w", err)
 	}
 	debugLog("Successfully created Key Vault client")
 
-	debugLog("Retrieving secret: %s", secretName)
-	response, err := client.GetSecret(ctx, secretName, "", nil)
-	if err != nil {
-		debugLog("Failed to retrieve secret '%s': %v", secretName, err)
-		return fmt.Errorf("failed to get secret '%s': %w", secretName, err)
-	}
-	debugLog("Successfully retrieved secret")
+	c.clients[vaultURL] = client
+	return client, nil
+}
 
-	if response.Value == nil {
-		debugLog("Secret '%s' has no value", secretName)
-		return fmt.Errorf("secret '%s' has no value", secretName)
+// requireFlags fails with cobra's wording when a flag was neither set on the
+// command line nor defaulted from its environment variable.
+func requireFlags(cmd *cobra.Command, names ...string) error {
+	var missing []string
+	for _, name := range names {
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil {
+			continue
+		}
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			if len(slice.GetSlice()) == 0 {
+				missing = append(missing, name)
+			}
+		} else if flag.Value.String() == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return usageErrorf(`required flag(s) "%s" not set`, strings.Join(missing, `", "`))
 	}
-
-	debugLog("Secret retrieved successfully, outputting to stdout")
-	fmt.Print(*response.Value)
-	debugLog("Operation completed successfully")
 	return nil
 }
 
@@ -119,6 +421,34 @@ func getEnvOrDefault(envVar, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvOrDefaultSlice splits a comma-separated environment variable into its
+// non-empty, trimmed elements.
+func getEnvOrDefaultSlice(envVar string, defaultValue []string) []string {
+	value := os.Getenv(envVar)
+	if value == "" {
+		return defaultValue
+	}
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	if len(values) == 0 {
+		return defaultValue
+	}
+	return values
+}
+
+func getEnvOrDefaultInt(envVar string, defaultValue int) int {
+	if value := os.Getenv(envVar); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
 func getEnvOrDefaultBool(envVar string, defaultValue bool) bool {
 	if value := os.Getenv(envVar); value != "" {
 		return value == "true" || value == "1" || value == "yes" || value == "on"
@@ -149,48 +479,160 @@ func debugLog(format string, args ...interface{}) {
 	}
 }
 
-func createCredential() (azcore.TokenCredential, error) {
-	debugLog("Creating credential for auth method: %s", authMethod)
+// authSettings are the settings a credential is created with. They come from
+// the authentication flags, or from the --to-* flags for the destination of copy.
+type authSettings struct {
+	method                    string
+	clientID                  string
+	tenantID                  string
+	clientSecret              string
+	clientSecretFile          string
+	clientSecretStdin         bool
+	userAssignedID            string
+	clientCertificate         string
+	clientCertificatePassword string
+	federatedTokenFile        string
+}
+
+// flagAuthSettings returns the settings given by the authentication flags.
+func flagAuthSettings() authSettings {
+	return authSettings{
+		method:                    authMethod,
+		clientID:                  clientID,
+		tenantID:                  tenantID,
+		clientSecret:              clientSecret,
+		clientSecretFile:          clientSecretFile,
+		clientSecretStdin:         clientSecretStdin,
+		userAssignedID:            userAssignedID,
+		clientCertificate:         clientCertificate,
+		clientCertificatePassword: clientCertificatePassword,
+		federatedTokenFile:        federatedTokenFile,
+	}
+}
 
-	switch authMethod {
+func createCredential(settings authSettings) (azcore.TokenCredential, error) {
+	debugLog("Creating credential for auth method: %s", settings.method)
+
+	switch settings.method {
 	case "default":
 		debugLog("Using DefaultAzureCredential")
-		return azidentity.NewDefaultAzureCredential(nil)
+		return azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+			ClientOptions:            credentialClientOptions(),
+			DisableInstanceDiscovery: authorityHost != "",
+		})
 
 	case "system-mi":
 		debugLog("Using system managed identity")
 		return azidentity.NewManagedIdentityCredential(nil)
 
 	case "user-mi":
-		if userAssignedID != "" {
-			debugLog("Using user-assigned managed identity with ID: %s", userAssignedID)
+		if settings.userAssignedID != "" {
+			debugLog("Using user-assigned managed identity with ID: %s", settings.userAssignedID)
 			options := &azidentity.ManagedIdentityCredentialOptions{
-				ID: azidentity.ClientID(userAssignedID),
+				ID: azidentity.ClientID(settings.userAssignedID),
 			}
 			return azidentity.NewManagedIdentityCredential(options)
-		} else if clientID != "" {
-			debugLog("Using user-assigned managed identity with client ID: %s", clientID)
+		} else if settings.clientID != "" {
+			debugLog("Using user-assigned managed identity with client ID: %s", settings.clientID)
 			options := &azidentity.ManagedIdentityCredentialOptions{
-				ID: azidentity.ClientID(clientID),
+				ID: azidentity.ClientID(settings.clientID),
 			}
 			return azidentity.NewManagedIdentityCredential(options)
 		}
 		debugLog("User-assigned managed identity requires client ID or user-assigned ID")
-		return nil, fmt.Errorf("user-assigned managed identity requires --client-id or --user-assigned-id")
+		return nil, usageErrorf("user-assigned managed identity requires --client-id or --user-assigned-id")
 
 	case "service-principal":
-		if clientID == "" || clientSecret == "" || tenantID == "" {
+		secret, err := resolveClientSecret(settings)
+		if err != nil {
+			debugLog("Failed to resolve client secret: %v", err)
+			return nil, err
+		}
+		if settings.clientID == "" || secret == "" || settings.tenantID == "" {
 			debugLog("Service principal authentication missing required parameters")
-			debugLog("  Client ID provided: %t", clientID != "")
-			debugLog("  Client Secret provided: %t", clientSecret != "")
-			debugLog("  Tenant ID provided: %t", tenantID != "")
-			return nil, fmt.Errorf("service principal authentication requires --client-id, --client-secret, and --tenant-id")
+			debugLog("  Client ID provided: %t", settings.clientID != "")
+			debugLog("  Client Secret provided: %t", secret != "")
+			debugLog("  Tenant ID provided: %t", settings.tenantID != "")
+			return nil, usageErrorf("service principal authentication requires --client-id, --client-secret, and --tenant-id (the secret may also be read with --client-secret-file or --client-secret-stdin)")
 		}
-		debugLog("Using service principal with client ID: %s, tenant ID: %s", clientID, tenantID)
-		return azidentity.NewClientSecretCredential(tenantID, clientID, clientSecret, nil)
+		debugLog("Using service principal with client ID: %s, tenant ID: %s", settings.clientID, settings.tenantID)
+		return azidentity.NewClientSecretCredential(settings.tenantID, settings.clientID, secret, &azidentity.ClientSecretCredentialOptions{
+			ClientOptions:            credentialClientOptions(),
+			DisableInstanceDiscovery: authorityHost != "",
+		})
+
+	case "service-principal-cert":
+		return createClientCertificateCredential(settings)
+
+	case "workload-identity":
+		return createWorkloadIdentityCredential(settings)
 
 	default:
-		debugLog("Unsupported authentication method: %s", authMethod)
-		return nil, fmt.Errorf("unsupported authentication method: %s", authMethod)
+		debugLog("Unsupported authentication method: %s", settings.method)
+		return nil, usageErrorf("unsupported authentication method: %s", settings.method)
+	}
+}
+
+// createClientCertificateCredential authenticates a service principal with a
+// PEM or PKCS#12 certificate instead of a client secret.
+func createClientCertificateCredential(settings authSettings) (azcore.TokenCredential, error) {
+	if settings.clientID == "" || settings.clientCertificate == "" || settings.tenantID == "" {
+		debugLog("Service principal certificate authentication missing required parameters")
+		debugLog("  Client ID provided: %t", settings.clientID != "")
+		debugLog("  Client Certificate provided: %t", settings.clientCertificate != "")
+		debugLog("  Tenant ID provided: %t", settings.tenantID != "")
+		return nil, usageErrorf("service principal certificate authentication requires --client-id, --client-certificate, and --tenant-id")
+	}
+
+	debugLog("Reading client certificate from: %s", settings.clientCertificate)
+	data, err := os.ReadFile(settings.clientCertificate)
+	if err != nil {
+		debugLog("Failed to read client certificate: %v", err)
+		return nil, fmt.Errorf("failed to read client certificate: %w", err)
+	}
+	certs, key, err := azidentity.ParseCertificates(data, []byte(settings.clientCertificatePassword))
+	if err != nil {
+		debugLog("Failed to parse client certificate: %v", err)
+		return nil, fmt.Errorf("failed to parse client certificate '%s': %w", settings.clientCertificate, err)
+	}
+
+	debugLog("Using service principal certificate with client ID: %s, tenant ID: %s, send chain: %t", settings.clientID, settings.tenantID, sendCertificateChain)
+	return azidentity.NewClientCertificateCredential(settings.tenantID, settings.clientID, certs, key, &azidentity.ClientCertificateCredentialOptions{
+		ClientOptions:            credentialClientOptions(),
+		DisableInstanceDiscovery: authorityHost != "",
+		SendCertificateChain:     sendCertificateChain,
+	})
+}
+
+// createWorkloadIdentityCredential exchanges a federated token, such as a
+// Kubernetes service account token or a GitHub Actions OIDC token written to
+// a file, for an Entra ID access token.
+func createWorkloadIdentityCredential(settings authSettings) (azcore.TokenCredential, error) {
+	if settings.clientID == "" || settings.tenantID == "" || settings.federatedTokenFile == "" {
+		debugLog("Workload identity authentication missing required parameters")
+		debugLog("  Client ID provided: %t", settings.clientID != "")
+		debugLog("  Tenant ID provided: %t", settings.tenantID != "")
+		debugLog("  Federated token file provided: %t", settings.federatedTokenFile != "")
+		return nil, usageErrorf("workload identity authentication requires --client-id, --tenant-id, and --federated-token-file")
+	}
+	debugLog("Using workload identity with client ID: %s, tenant ID: %s, token file: %s", settings.clientID, settings.tenantID, settings.federatedTokenFile)
+	return azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+		ClientOptions:            credentialClientOptions(),
+		ClientID:                 settings.clientID,
+		TenantID:                 settings.tenantID,
+		TokenFilePath:            settings.federatedTokenFile,
+		DisableInstanceDiscovery: authorityHost != "",
+	})
+}
+
+// credentialClientOptions applies --authority-host to Entra ID credentials.
+// Instance discovery is skipped for custom authorities, which would otherwise
+// be rejected as unknown.
+func credentialClientOptions() azcore.ClientOptions {
+	options := azcore.ClientOptions{Transport: credentialTransport}
+	if authorityHost != "" {
+		debugLog("Using authority host: %s", authorityHost)
+		options.Cloud = cloud.Configuration{ActiveDirectoryAuthorityHost: authorityHost}
 	}
+	return options
 }
@@ -1,8 +1,23 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 )
 
 func TestGetEnvOrDefaultBool(t *testing.T) {
@@ -144,11 +159,84 @@ func TestGetEnvOrDefault(t *testing.T) {
 	}
 }
 
+func TestGetEnvOrDefaultSlice(t *testing.T) {
+	tests := []struct {
+		name         string
+		envValue     string
+		defaultValue []string
+		expected     []string
+	}{
+		{
+			name:     "single value",
+			envValue: "db-password",
+			expected: []string{"db-password"},
+		},
+		{
+			name:     "comma-separated values are trimmed",
+			envValue: "api-key, db-password ,,cert",
+			expected: []string{"api-key", "db-password", "cert"},
+		},
+		{
+			name:         "only separators falls back to default",
+			envValue:     " , ",
+			defaultValue: []string{"fallback"},
+			expected:     []string{"fallback"},
+		},
+		{
+			name:         "environment variable not set",
+			defaultValue: []string{"fallback"},
+			expected:     []string{"fallback"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_SLICE_VAR", tt.envValue)
+
+			result := getEnvOrDefaultSlice("TEST_SLICE_VAR", tt.defaultValue)
+			if strings.Join(result, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("getEnvOrDefaultSlice(TEST_SLICE_VAR, %v) = %v; want %v",
+					tt.defaultValue, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetEnvOrDefaultInt(t *testing.T) {
+	tests := []struct {
+		name     string
+		envValue string
+		expected int
+	}{
+		{"valid integer", "8", 8},
+		{"invalid integer falls back to default", "eight", 4},
+		{"environment variable not set", "", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_INT_VAR", tt.envValue)
+
+			result := getEnvOrDefaultInt("TEST_INT_VAR", 4)
+			if result != tt.expected {
+				t.Errorf("getEnvOrDefaultInt(TEST_INT_VAR, 4) = %d; want %d", result, tt.expected)
+			}
+		})
+	}
+}
+
 func TestCreateCredential(t *testing.T) {
 	// Disable debug logging for tests
 	originalDebug := debug
 	debug = false
 	defer func() { debug = originalDebug }()
+
+	certificate := writeTestCertificate(t)
+	notACertificate := filepath.Join(t.TempDir(), "not-a-cert.pem")
+	if err := os.WriteFile(notACertificate, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
 	tests := []struct {
 		name           string
 		authMethod     string
@@ -156,6 +244,8 @@ func TestCreateCredential(t *testing.T) {
 		clientSecret   string
 		tenantID       string
 		userAssignedID string
+		tokenFile      string
+		certificate    string
 		shouldError    bool
 		errorContains  string
 	}{
@@ -219,6 +309,70 @@ func TestCreateCredential(t *testing.T) {
 			shouldError:   true,
 			errorContains: "requires --client-id, --client-secret, and --tenant-id",
 		},
+		{
+			name:        "service principal certificate with all params",
+			authMethod:  "service-principal-cert",
+			clientID:    "test-client-id",
+			tenantID:    "test-tenant-id",
+			certificate: certificate,
+		},
+		{
+			name:          "service principal certificate missing certificate",
+			authMethod:    "service-principal-cert",
+			clientID:      "test-client-id",
+			tenantID:      "test-tenant-id",
+			shouldError:   true,
+			errorContains: "requires --client-id, --client-certificate, and --tenant-id",
+		},
+		{
+			name:          "service principal certificate missing client-id",
+			authMethod:    "service-principal-cert",
+			tenantID:      "test-tenant-id",
+			certificate:   certificate,
+			shouldError:   true,
+			errorContains: "requires --client-id, --client-certificate, and --tenant-id",
+		},
+		{
+			name:          "service principal certificate file not found",
+			authMethod:    "service-principal-cert",
+			clientID:      "test-client-id",
+			tenantID:      "test-tenant-id",
+			certificate:   filepath.Join(t.TempDir(), "missing.pem"),
+			shouldError:   true,
+			errorContains: "failed to read client certificate",
+		},
+		{
+			name:          "service principal certificate invalid contents",
+			authMethod:    "service-principal-cert",
+			clientID:      "test-client-id",
+			tenantID:      "test-tenant-id",
+			certificate:   notACertificate,
+			shouldError:   true,
+			errorContains: "failed to parse client certificate",
+		},
+		{
+			name:       "workload identity with all params",
+			authMethod: "workload-identity",
+			clientID:   "test-client-id",
+			tenantID:   "test-tenant-id",
+			tokenFile:  "/var/run/secrets/azure/tokens/azure-identity-token",
+		},
+		{
+			name:          "workload identity missing token file",
+			authMethod:    "workload-identity",
+			clientID:      "test-client-id",
+			tenantID:      "test-tenant-id",
+			shouldError:   true,
+			errorContains: "requires --client-id, --tenant-id, and --federated-token-file",
+		},
+		{
+			name:          "workload identity missing tenant-id",
+			authMethod:    "workload-identity",
+			clientID:      "test-client-id",
+			tokenFile:     "/var/run/secrets/azure/tokens/azure-identity-token",
+			shouldError:   true,
+			errorContains: "requires --client-id, --tenant-id, and --federated-token-file",
+		},
 		{
 			name:          "unsupported auth method",
 			authMethod:    "invalid-method",
@@ -229,14 +383,15 @@ func TestCreateCredential(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Set global variables as they would be set by cobra
-			authMethod = tt.authMethod
-			clientID = tt.clientID
-			clientSecret = tt.clientSecret
-			tenantID = tt.tenantID
-			userAssignedID = tt.userAssignedID
-
-			credential, err := createCredential()
+			credential, err := createCredential(authSettings{
+				method:             tt.authMethod,
+				clientID:           tt.clientID,
+				clientSecret:       tt.clientSecret,
+				tenantID:           tt.tenantID,
+				userAssignedID:     tt.userAssignedID,
+				federatedTokenFile: tt.tokenFile,
+				clientCertificate:  tt.certificate,
+			})
 
 			if tt.shouldError {
 				if err == nil {
@@ -260,6 +415,97 @@ func TestCreateCredential(t *testing.T) {
 	}
 }
 
+// writeTestCertificate writes a self-signed certificate and its private key
+// as PEM and returns the file path.
+func writeTestCertificate(t *testing.T) string {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "azkeyget-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	var data []byte
+	data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	data = append(data, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})...)
+
+	path := filepath.Join(t.TempDir(), "client.pem")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	return path
+}
+
+func TestWorkloadIdentityCredentialToken(t *testing.T) {
+	const tenant = "test-tenant-id"
+	const assertion = "federated-token-from-file"
+
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/" + tenant + "/v2.0/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{"token_endpoint": "%[1]s/%[2]s/oauth2/v2.0/token", "authorization_endpoint": "%[1]s/%[2]s/oauth2/v2.0/authorize", "issuer": "%[1]s/%[2]s/v2.0"}`, server.URL, tenant)
+		case "/" + tenant + "/oauth2/v2.0/token":
+			if err := r.ParseForm(); err != nil {
+				t.Errorf("Failed to parse token request: %v", err)
+			}
+			if got := r.PostForm.Get("client_assertion"); got != assertion {
+				t.Errorf("client_assertion = %q; want %q", got, assertion)
+			}
+			if got := r.PostForm.Get("client_id"); got != "test-client-id" {
+				t.Errorf("client_id = %q; want test-client-id", got)
+			}
+			fmt.Fprint(w, `{"access_token": "stub-access-token", "expires_in": 3600, "token_type": "Bearer"}`)
+		default:
+			t.Errorf("Unexpected request to stub authority: %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte(assertion), 0o600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+
+	originalTransport := credentialTransport
+	credentialTransport = server.Client()
+	defer func() { credentialTransport = originalTransport }()
+
+	authorityHost = server.URL
+	defer func() { authorityHost = "" }()
+
+	credential, err := createCredential(authSettings{
+		method:             "workload-identity",
+		clientID:           "test-client-id",
+		tenantID:           tenant,
+		federatedTokenFile: tokenFile,
+	})
+	if err != nil {
+		t.Fatalf("createCredential() unexpected error: %v", err)
+	}
+	token, err := credential.GetToken(context.Background(), policy.TokenRequestOptions{
+		Scopes: []string{"https://vault.azure.net/.default"},
+	})
+	if err != nil {
+		t.Fatalf("GetToken() unexpected error: %v", err)
+	}
+	if token.Token != "stub-access-token" {
+		t.Errorf("GetToken() token = %s; want stub-access-token", token.Token)
+	}
+}
+
 // Helper function to check if a string contains a substring
 func containsString(s, substr string) bool {
 	return len(s) >= len(substr) &&
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"gopkg.in/yaml.v3"
+)
+
+// manifest lists the secrets a service uses, for --manifest.
+type manifest struct {
+	Vault   string          `yaml:"vault"`
+	Secrets []manifestEntry `yaml:"secrets"`
+
+	refs []secretRef // resolved references, one per entry
+}
+
+// manifestEntry describes one secret in a manifest. Required defaults to true.
+type manifestEntry struct {
+	Vault    string  `yaml:"vault"`
+	Name     string  `yaml:"name"`
+	Version  string  `yaml:"version"`
+	Env      string  `yaml:"env"`
+	Required *bool   `yaml:"required"`
+	Default  *string `yaml:"default"`
+	Decode   string  `yaml:"decode"`
+}
+
+// required reports whether a missing secret is an error.
+func (e manifestEntry) required() bool {
+	return e.Required == nil || *e.Required
+}
+
+// loadManifest reads a YAML (or JSON) manifest and resolves the vault of every
+// entry: the entry's own vault, else the manifest vault, else --vault-url.
+// Vaults may be given as URLs or bare vault names.
+func loadManifest(path string) (*manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, usageErrorf("failed to read manifest: %w", err)
+	}
+
+	var m manifest
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&m); err != nil && !errors.Is(err, io.EOF) {
+		return nil, usageErrorf("failed to parse manifest '%s': %w", path, err)
+	}
+	if len(m.Secrets) == 0 {
+		return nil, usageErrorf("manifest '%s' lists no secrets", path)
+	}
+
+	envNames := map[string]bool{}
+	for i, entry := range m.Secrets {
+		if entry.Name == "" {
+			return nil, usageErrorf("manifest entry %d has no name", i+1)
+		}
+		if entry.Decode != "" && entry.Decode != "base64" {
+			return nil, usageErrorf("manifest entry '%s': unsupported decode '%s' (supported: base64)", entry.Name, entry.Decode)
+		}
+		if entry.Env != "" {
+			if !envNamePattern.MatchString(entry.Env) {
+				return nil, usageErrorf("manifest entry '%s': '%s' is not a valid environment variable name", entry.Name, entry.Env)
+			}
+			if envNames[entry.Env] {
+				return nil, usageErrorf("manifest entry '%s': env '%s' is used by more than one entry", entry.Name, entry.Env)
+			}
+			envNames[entry.Env] = true
+		}
+
+		ref, err := entry.ref(m.Vault)
+		if err != nil {
+			return nil, err
+		}
+		m.refs = append(m.refs, ref)
+	}
+	return &m, nil
+}
+
+// ref resolves the secret an entry refers to. The name may also be a full
+// secret reference, which then determines the vault.
+func (e manifestEntry) ref(defaultVault string) (secretRef, error) {
+	if isSecretReference(e.Name) {
+		ref, err := parseSecretReference(e.Name)
+		if err != nil {
+			return secretRef{}, withClass(classUsage, err)
+		}
+		if e.Version != "" {
+			ref.version = e.Version
+		}
+		return ref, nil
+	}
+
+	vault := e.Vault
+	if vault == "" {
+		vault = defaultVault
+	}
+	if vault == "" {
+		vault = vaultURL
+	}
+	if vault == "" {
+		return secretRef{}, usageErrorf("manifest entry '%s' has no vault; set vault in the entry or the manifest, or pass --vault-url", e.Name)
+	}
+	return secretRef{vault: vaultURLFromName(vault), name: e.Name, version: e.Version}, nil
+}
+
+// resolve applies the entries to the retrieved secrets, which must be in
+// manifest order. A secret that does not exist is replaced by its default, or
+// left out when it is not required; any other failure is kept. Values are
+// decoded as requested.
+func (m *manifest) resolve(results []secretResult) []secretResult {
+	resolved := make([]secretResult, 0, len(results))
+	for i, result := range results {
+		entry := m.Secrets[i]
+		result.env = entry.Env
+
+		if result.err != nil && classifyError(result.err).Code == classNotFound.code {
+			switch {
+			case entry.Default != nil:
+				debugLog("Secret '%s' not found, using its default", entry.Name)
+				result.secret = azsecrets.Secret{Value: entry.Default}
+				result.err = nil
+				resolved = append(resolved, result)
+				continue
+			case !entry.required():
+				debugLog("Optional secret '%s' not found, skipping", entry.Name)
+				continue
+			}
+		}
+
+		if result.err == nil && entry.Decode == "base64" {
+			decoded, err := base64.StdEncoding.DecodeString(*result.secret.Value)
+			if err != nil {
+				result.err = fmt.Errorf("secret '%s' is not valid base64: %w", entry.Name, err)
+			} else {
+				value := string(decoded)
+				result.secret.Value = &value
+			}
+		}
+		resolved = append(resolved, result)
+	}
+	return resolved
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeManifest writes a manifest into a temporary directory and returns its path.
+func writeManifest(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "secrets.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+	return path
+}
+
+func TestLoadManifest(t *testing.T) {
+	previous := vaultURL
+	t.Cleanup(func() { vaultURL = previous })
+
+	tests := []struct {
+		name          string
+		content       string
+		vaultURL      string
+		expected      []secretRef
+		errorContains string
+	}{
+		{
+			name: "vault from entry, manifest and flag",
+			content: `
+vault: shared
+secrets:
+  - name: a
+    vault: https://own.vault.azure.net/
+  - name: b
+  - name: c
+    vault: other
+    version: v1
+`,
+			expected: []secretRef{
+				{vault: "https://own.vault.azure.net/", name: "a"},
+				{vault: "https://shared.vault.azure.net/", name: "b"},
+				{vault: "https://other.vault.azure.net/", name: "c", version: "v1"},
+			},
+		},
+		{
+			name:     "vault url flag is the fallback",
+			content:  "secrets:\n  - name: a\n",
+			vaultURL: "https://flag.vault.azure.net/",
+			expected: []secretRef{{vault: "https://flag.vault.azure.net/", name: "a"}},
+		},
+		{
+			name:     "name may be a full reference",
+			content:  "secrets:\n  - name: https://ref.vault.azure.net/secrets/a/v2\n",
+			expected: []secretRef{{vault: "https://ref.vault.azure.net/", name: "a", version: "v2"}},
+		},
+		{
+			name:     "json manifest",
+			content:  `{"vault": "shared", "secrets": [{"name": "a", "env": "A"}]}`,
+			expected: []secretRef{{vault: "https://shared.vault.azure.net/", name: "a"}},
+		},
+		{
+			name:          "no vault",
+			content:       "secrets:\n  - name: a\n",
+			errorContains: "manifest entry 'a' has no vault",
+		},
+		{
+			name:          "no secrets",
+			content:       "vault: shared\n",
+			errorContains: "lists no secrets",
+		},
+		{
+			name:          "entry without name",
+			content:       "vault: shared\nsecrets:\n  - env: A\n",
+			errorContains: "manifest entry 1 has no name",
+		},
+		{
+			name:          "unsupported decode",
+			content:       "vault: shared\nsecrets:\n  - name: a\n    decode: hex\n",
+			errorContains: "unsupported decode 'hex'",
+		},
+		{
+			name:          "invalid env name",
+			content:       "vault: shared\nsecrets:\n  - name: a\n    env: 1A\n",
+			errorContains: "'1A' is not a valid environment variable name",
+		},
+		{
+			name:          "duplicate env name",
+			content:       "vault: shared\nsecrets:\n  - name: a\n    env: A\n  - name: b\n    env: A\n",
+			errorContains: "env 'A' is used by more than one entry",
+		},
+		{
+			name:          "unknown field",
+			content:       "vault: shared\nsecrets:\n  - name: a\n    optional: true\n",
+			errorContains: "field optional not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vaultURL = tt.vaultURL
+			m, err := loadManifest(writeManifest(t, tt.content))
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("loadManifest() error = %v; should contain %s", err, tt.errorContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadManifest() unexpected error: %v", err)
+			}
+			if len(m.refs) != len(tt.expected) {
+				t.Fatalf("loadManifest() returned %d refs; want %d", len(m.refs), len(tt.expected))
+			}
+			for i, ref := range m.refs {
+				if ref != tt.expected[i] {
+					t.Errorf("ref[%d] = %+v; want %+v", i, ref, tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGetSecretWithManifest(t *testing.T) {
+	cleanTestEnvironment(t)
+	app := newFakeVault("manifest-app")
+	app.add("db-password", "hunter2")
+	app.add("tls-key", "LS0tLS1CRUdJTi0tLS0t")
+	app.add("not-base64", "%%%")
+	shared := newFakeVault("manifest-shared")
+	shared.add("api-key", "abc123")
+	installFakeVaults(t, app, shared)
+
+	tests := []struct {
+		name          string
+		content       string
+		format        string
+		expected      string
+		errorContains string
+		exitCode      int
+	}{
+		{
+			name: "secrets across vaults with env names",
+			content: `
+vault: ` + app.url() + `
+secrets:
+  - name: db-password
+    env: DB_PASSWORD
+  - name: api-key
+    vault: ` + shared.url() + `
+`,
+			format:   "dotenv",
+			expected: "DB_PASSWORD=hunter2\nAPI_KEY=abc123\n",
+		},
+		{
+			name: "base64 decoding",
+			content: `
+vault: ` + app.url() + `
+secrets:
+  - name: tls-key
+    env: TLS_KEY
+    decode: base64
+`,
+			format:   "export",
+			expected: "export TLS_KEY='-----BEGIN-----'\n",
+		},
+		{
+			name: "missing secrets use their default or are skipped when optional",
+			content: `
+vault: ` + app.url() + `
+secrets:
+  - name: log-level
+    env: LOG_LEVEL
+    default: info
+  - name: feature-flags
+    required: false
+  - name: db-password
+`,
+			format:   "dotenv",
+			expected: "LOG_LEVEL=info\nDB_PASSWORD=hunter2\n",
+		},
+		{
+			name: "json output includes env names",
+			content: `
+vault: ` + app.url() + `
+secrets:
+  - name: log-level
+    env: LOG_LEVEL
+    default: info
+`,
+			format:   "json",
+			expected: "{\n  \"name\": \"log-level\",\n  \"env\": \"LOG_LEVEL\",\n  \"value\": \"info\"\n}\n",
+		},
+		{
+			name: "missing required secret fails",
+			content: `
+vault: ` + app.url() + `
+secrets:
+  - name: missing
+  - name: db-password
+`,
+			format:        "dotenv",
+			expected:      "DB_PASSWORD=hunter2\n",
+			errorContains: "failed to retrieve 1 of 2 secrets",
+			exitCode:      classNotFound.code,
+		},
+		{
+			name: "invalid base64",
+			content: `
+vault: ` + app.url() + `
+secrets:
+  - name: not-base64
+    decode: base64
+`,
+			format:        "raw",
+			errorContains: "secret 'not-base64' is not valid base64",
+			exitCode:      classError.code,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := executeCommand(t, "--manifest", writeManifest(t, tt.content), "--output", tt.format)
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("executeCommand() error = %v; should contain %s", err, tt.errorContains)
+				}
+				if err != nil && classifyError(err).Code != tt.exitCode {
+					t.Errorf("exit code = %d; want %d", classifyError(err).Code, tt.exitCode)
+				}
+			} else if err != nil {
+				t.Fatalf("executeCommand() unexpected error: %v", err)
+			}
+			if out != tt.expected {
+				t.Errorf("executeCommand() = %q; want %q", out, tt.expected)
+			}
+		})
+	}
+}
+
+func TestManifestConflicts(t *testing.T) {
+	cleanTestEnvironment(t)
+	path := writeManifest(t, "vault: shared\nsecrets:\n  - name: a\n")
+
+	_, err := executeCommand(t, "--manifest", path, "--secret", "b")
+	if err == nil || !strings.Contains(err.Error(), "--manifest cannot be combined") {
+		t.Errorf("executeCommand() error = %v; want conflict with --secret", err)
+	}
+}
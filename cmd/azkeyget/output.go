@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// outputFormats lists the values accepted by --output.
+var outputFormats = []string{"raw", "json", "yaml", "dotenv", "export", "k8s-secret"}
+
+// dotenvSafePattern matches values that need no quoting in a dotenv file.
+var dotenvSafePattern = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]*$`)
+
+// secretOutput is the structured representation of a retrieved secret.
+type secretOutput struct {
+	Name        string            `json:"name" yaml:"name"`
+	Env         string            `json:"env,omitempty" yaml:"env,omitempty"`
+	Value       string            `json:"value" yaml:"value"`
+	ID          string            `json:"id,omitempty" yaml:"id,omitempty"`
+	Version     string            `json:"version,omitempty" yaml:"version,omitempty"`
+	ContentType string            `json:"contentType,omitempty" yaml:"contentType,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Enabled     *bool             `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	Created     *time.Time        `json:"created,omitempty" yaml:"created,omitempty"`
+	Updated     *time.Time        `json:"updated,omitempty" yaml:"updated,omitempty"`
+	Expires     *time.Time        `json:"expires,omitempty" yaml:"expires,omitempty"`
+	NotBefore   *time.Time        `json:"notBefore,omitempty" yaml:"notBefore,omitempty"`
+}
+
+// validateOutputFormat rejects unknown --output values before any secret is retrieved.
+func validateOutputFormat(format string) error {
+	for _, supported := range outputFormats {
+		if format == supported {
+			return nil
+		}
+	}
+	return usageErrorf("unsupported output format: %s (supported: %s)", format, strings.Join(outputFormats, ", "))
+}
+
+// writeSecrets writes every successfully retrieved secret in format.
+// Structured formats produce a single object for one secret and a list for
+// several.
+func writeSecrets(w io.Writer, format string, results []secretResult) error {
+	if format == "raw" {
+		writeSecretValues(w, results)
+		return nil
+	}
+
+	outputs := []secretOutput{}
+	for _, result := range results {
+		if result.err == nil {
+			outputs = append(outputs, newSecretOutput(result))
+		}
+	}
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if len(results) == 1 && len(outputs) == 1 {
+			return encoder.Encode(outputs[0])
+		}
+		return encoder.Encode(outputs)
+	case "yaml":
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if len(results) == 1 && len(outputs) == 1 {
+			return encoder.Encode(outputs[0])
+		}
+		return encoder.Encode(outputs)
+	case "dotenv":
+		for _, output := range outputs {
+			fmt.Fprintf(w, "%s=%s\n", output.envName(), quoteDotenv(output.Value))
+		}
+		return nil
+	case "export":
+		for _, output := range outputs {
+			fmt.Fprintf(w, "export %s=%s\n", output.envName(), quoteShell(output.Value))
+		}
+		return nil
+	case "k8s-secret":
+		return writeK8sSecret(w, outputs)
+	default:
+		return validateOutputFormat(format)
+	}
+}
+
+// newSecretOutput flattens a retrieved secret and its attributes.
+func newSecretOutput(result secretResult) secretOutput {
+	secret := result.secret
+	output := secretOutput{
+		Name:  result.name,
+		Env:   result.env,
+		Value: *secret.Value,
+	}
+	if secret.ID != nil {
+		output.ID = string(*secret.ID)
+		output.Version = secret.ID.Version()
+	}
+	if secret.ContentType != nil {
+		output.ContentType = *secret.ContentType
+	}
+	if len(secret.Tags) > 0 {
+		output.Tags = make(map[string]string, len(secret.Tags))
+		for key, value := range secret.Tags {
+			if value != nil {
+				output.Tags[key] = *value
+			}
+		}
+	}
+	if attrs := secret.Attributes; attrs != nil {
+		output.Enabled = attrs.Enabled
+		output.Created = attrs.Created
+		output.Updated = attrs.Updated
+		output.Expires = attrs.Expires
+		output.NotBefore = attrs.NotBefore
+	}
+	return output
+}
+
+// envName returns the environment variable name of the output: the name set
+// in a manifest, or one derived from the secret name.
+func (o secretOutput) envName() string {
+	if o.Env != "" {
+		return o.Env
+	}
+	return envVarName(o.Name)
+}
+
+// envVarName converts a secret name into an environment variable name, for
+// example db-password becomes DB_PASSWORD.
+func envVarName(secretName string) string {
+	var b strings.Builder
+	for i, r := range strings.ToUpper(secretName) {
+		switch {
+		case r >= 'A' && r <= 'Z', r == '_':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// quoteDotenv quotes a value for a dotenv file. Simple values are written
+// as-is, values without single quotes or line breaks are single-quoted so they
+// are taken literally, and anything else is double-quoted with escapes.
+func quoteDotenv(value string) string {
+	if dotenvSafePattern.MatchString(value) {
+		return value
+	}
+	if !strings.ContainsAny(value, "'\n\r") {
+		return "'" + value + "'"
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "$", `\$`)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+// quoteShell single-quotes a value so that it is safe to eval in a POSIX shell.
+func quoteShell(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
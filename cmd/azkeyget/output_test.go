@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"gopkg.in/yaml.v3"
+)
+
+// testResult builds a successful secretResult with fixed metadata.
+func testResult(name, value string) secretResult {
+	id := azsecrets.ID("https://example.vault.azure.net/secrets/" + name + "/abc123")
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	return secretResult{
+		name: name,
+		secret: azsecrets.Secret{
+			ID:          &id,
+			Value:       to.Ptr(value),
+			ContentType: to.Ptr("text/plain"),
+			Tags:        map[string]*string{"env": to.Ptr("prod")},
+			Attributes: &azsecrets.SecretAttributes{
+				Enabled: to.Ptr(true),
+				Created: &created,
+			},
+		},
+	}
+}
+
+func TestWriteSecretsJSON(t *testing.T) {
+	var single bytes.Buffer
+	if err := writeSecrets(&single, "json", []secretResult{testResult("api-key", "s3cret")}); err != nil {
+		t.Fatalf("writeSecrets() unexpected error: %v", err)
+	}
+
+	var output secretOutput
+	if err := json.Unmarshal(single.Bytes(), &output); err != nil {
+		t.Fatalf("single secret JSON is not an object: %v\n%s", err, single.String())
+	}
+	if output.Name != "api-key" || output.Value != "s3cret" || output.Version != "abc123" ||
+		output.ContentType != "text/plain" || output.Tags["env"] != "prod" ||
+		output.Enabled == nil || !*output.Enabled || output.Created == nil {
+		t.Errorf("writeSecrets() JSON = %s", single.String())
+	}
+	if strings.Contains(single.String(), "expires") {
+		t.Errorf("writeSecrets() JSON should omit unset fields: %s", single.String())
+	}
+
+	var multiple bytes.Buffer
+	results := []secretResult{testResult("api-key", "one"), testResult("db-password", "two")}
+	if err := writeSecrets(&multiple, "json", results); err != nil {
+		t.Fatalf("writeSecrets() unexpected error: %v", err)
+	}
+	var outputs []secretOutput
+	if err := json.Unmarshal(multiple.Bytes(), &outputs); err != nil {
+		t.Fatalf("multiple secret JSON is not a list: %v\n%s", err, multiple.String())
+	}
+	if len(outputs) != 2 || outputs[1].Name != "db-password" || outputs[1].Value != "two" {
+		t.Errorf("writeSecrets() JSON = %s", multiple.String())
+	}
+}
+
+func TestWriteSecretsYAML(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeSecrets(&out, "yaml", []secretResult{testResult("api-key", "line1\nline2")}); err != nil {
+		t.Fatalf("writeSecrets() unexpected error: %v", err)
+	}
+
+	var output secretOutput
+	if err := yaml.Unmarshal(out.Bytes(), &output); err != nil {
+		t.Fatalf("writeSecrets() YAML does not parse: %v\n%s", err, out.String())
+	}
+	if output.Name != "api-key" || output.Value != "line1\nline2" || output.Tags["env"] != "prod" {
+		t.Errorf("writeSecrets() YAML = %s", out.String())
+	}
+}
+
+func TestWriteSecretsDotenv(t *testing.T) {
+	results := []secretResult{
+		testResult("api-key", "plain-value"),
+		testResult("db-password", "has spaces $HOME"),
+		testResult("cert", "it's\nmultiline"),
+		testResult("9lives", ""),
+	}
+
+	var out bytes.Buffer
+	if err := writeSecrets(&out, "dotenv", results); err != nil {
+		t.Fatalf("writeSecrets() unexpected error: %v", err)
+	}
+
+	expected := strings.Join([]string{
+		`API_KEY=plain-value`,
+		`DB_PASSWORD='has spaces $HOME'`,
+		`CERT="it's\nmultiline"`,
+		`_9LIVES=`,
+	}, "\n") + "\n"
+	if out.String() != expected {
+		t.Errorf("writeSecrets() dotenv = %q; want %q", out.String(), expected)
+	}
+}
+
+func TestWriteSecretsExport(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("export output is evaluated with a POSIX shell")
+	}
+
+	value := "it's $(not) `run`\nsecond line"
+	var out bytes.Buffer
+	if err := writeSecrets(&out, "export", []secretResult{testResult("db-password", value)}); err != nil {
+		t.Fatalf("writeSecrets() unexpected error: %v", err)
+	}
+
+	script := out.String() + `printf '%s' "$DB_PASSWORD"`
+	got, err := exec.Command("sh", "-c", script).Output()
+	if err != nil {
+		t.Fatalf("evaluating export output failed: %v\n%s", err, out.String())
+	}
+	if string(got) != value {
+		t.Errorf("eval of export output = %q; want %q", got, value)
+	}
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	for _, format := range outputFormats {
+		if err := validateOutputFormat(format); err != nil {
+			t.Errorf("validateOutputFormat(%s) unexpected error: %v", format, err)
+		}
+	}
+	if err := validateOutputFormat("xml"); err == nil || !strings.Contains(err.Error(), "unsupported output format: xml") {
+		t.Errorf("validateOutputFormat(xml) error = %v", err)
+	}
+}
+
+func TestEnvVarName(t *testing.T) {
+	tests := map[string]string{
+		"db-password": "DB_PASSWORD",
+		"ApiKey":      "APIKEY",
+		"1password":   "_1PASSWORD",
+		"a1-b2":       "A1_B2",
+	}
+	for name, expected := range tests {
+		if got := envVarName(name); got != expected {
+			t.Errorf("envVarName(%s) = %s; want %s", name, got, expected)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// vaultDNSSuffix is used to build a vault URL from a bare vault name.
+const vaultDNSSuffix = "vault.azure.net"
+
+// appServicePrefix introduces an App Service style Key Vault reference.
+const appServicePrefix = "@Microsoft.KeyVault("
+
+// isSecretReference reports whether s is a full secret reference rather than a
+// bare secret name.
+func isSecretReference(s string) bool {
+	return strings.HasPrefix(strings.ToLower(s), "https://") || hasFoldPrefix(s, appServicePrefix)
+}
+
+// parseSecretReference parses one of the supported secret reference forms:
+//
+//	https://myvault.vault.azure.net/secrets/name[/version]
+//	@Microsoft.KeyVault(SecretUri=https://myvault.vault.azure.net/secrets/name[/version])
+//	@Microsoft.KeyVault(VaultName=myvault;SecretName=name[;SecretVersion=version])
+func parseSecretReference(s string) (secretRef, error) {
+	s = strings.TrimSpace(s)
+	if hasFoldPrefix(s, appServicePrefix) {
+		return parseAppServiceReference(s)
+	}
+	return parseSecretURI(s)
+}
+
+// parseSecretURI parses a Key Vault secret identifier.
+func parseSecretURI(s string) (secretRef, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return secretRef{}, fmt.Errorf("invalid secret URI '%s': %w", s, err)
+	}
+	if !strings.EqualFold(u.Scheme, "https") || u.Host == "" {
+		return secretRef{}, fmt.Errorf("invalid secret URI '%s': expected https://<vault>/secrets/<name>[/<version>]", s)
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) < 2 || len(segments) > 3 || segments[0] != "secrets" || segments[1] == "" {
+		return secretRef{}, fmt.Errorf("invalid secret URI '%s': expected https://<vault>/secrets/<name>[/<version>]", s)
+	}
+
+	ref := secretRef{
+		vault: "https://" + u.Host + "/",
+		name:  segments[1],
+	}
+	if len(segments) == 3 {
+		ref.version = segments[2]
+	}
+	return ref, nil
+}
+
+// parseAppServiceReference parses an @Microsoft.KeyVault(...) reference as used
+// by App Service and Azure Functions application settings.
+func parseAppServiceReference(s string) (secretRef, error) {
+	if !strings.HasSuffix(s, ")") {
+		return secretRef{}, fmt.Errorf("invalid Key Vault reference '%s': missing closing parenthesis", s)
+	}
+	body := s[len(appServicePrefix) : len(s)-1]
+
+	params := map[string]string{}
+	for _, pair := range strings.Split(body, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, found := strings.Cut(pair, "=")
+		if !found {
+			return secretRef{}, fmt.Errorf("invalid Key Vault reference '%s': expected key=value, got '%s'", s, pair)
+		}
+		params[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+	}
+
+	if uri, ok := params["secreturi"]; ok {
+		return parseSecretURI(uri)
+	}
+
+	vaultName, secretName := params["vaultname"], params["secretname"]
+	if vaultName == "" || secretName == "" {
+		return secretRef{}, fmt.Errorf("invalid Key Vault reference '%s': requires SecretUri or VaultName and SecretName", s)
+	}
+	return secretRef{
+		vault:   vaultURLFromName(vaultName),
+		name:    secretName,
+		version: params["secretversion"],
+	}, nil
+}
+
+// vaultURLFromName returns the URL of a vault given by name, such as myvault.
+// Values that are already URLs are returned unchanged.
+func vaultURLFromName(vault string) string {
+	if strings.Contains(vault, "://") {
+		return vault
+	}
+	return fmt.Sprintf("https://%s.%s/", vault, vaultDNSSuffix)
+}
+
+// hasFoldPrefix reports whether s begins with prefix, ignoring case.
+func hasFoldPrefix(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSecretReference(t *testing.T) {
+	tests := []struct {
+		name          string
+		reference     string
+		expected      secretRef
+		errorContains string
+	}{
+		{
+			name:      "secret URI without version",
+			reference: "https://myvault.vault.azure.net/secrets/db-password",
+			expected:  secretRef{vault: "https://myvault.vault.azure.net/", name: "db-password"},
+		},
+		{
+			name:      "secret URI with version and trailing slash",
+			reference: "https://myvault.vault.azure.net/secrets/db-password/abc123/",
+			expected:  secretRef{vault: "https://myvault.vault.azure.net/", name: "db-password", version: "abc123"},
+		},
+		{
+			name:      "app service SecretUri reference",
+			reference: "@Microsoft.KeyVault(SecretUri=https://myvault.vault.azure.net/secrets/api-key/abc123)",
+			expected:  secretRef{vault: "https://myvault.vault.azure.net/", name: "api-key", version: "abc123"},
+		},
+		{
+			name:      "app service VaultName reference",
+			reference: "@Microsoft.KeyVault(VaultName=myvault;SecretName=api-key)",
+			expected:  secretRef{vault: "https://myvault.vault.azure.net/", name: "api-key"},
+		},
+		{
+			name:      "app service VaultName reference with version and odd casing",
+			reference: "@microsoft.keyvault( vaultname=myvault; secretname=api-key; secretversion=abc123 )",
+			expected:  secretRef{vault: "https://myvault.vault.azure.net/", name: "api-key", version: "abc123"},
+		},
+		{
+			name:          "http scheme",
+			reference:     "http://myvault.vault.azure.net/secrets/api-key",
+			errorContains: "expected https://<vault>/secrets/<name>[/<version>]",
+		},
+		{
+			name:          "key URI",
+			reference:     "https://myvault.vault.azure.net/keys/api-key",
+			errorContains: "expected https://<vault>/secrets/<name>[/<version>]",
+		},
+		{
+			name:          "too many path segments",
+			reference:     "https://myvault.vault.azure.net/secrets/api-key/abc/extra",
+			errorContains: "expected https://<vault>/secrets/<name>[/<version>]",
+		},
+		{
+			name:          "app service reference missing secret name",
+			reference:     "@Microsoft.KeyVault(VaultName=myvault)",
+			errorContains: "requires SecretUri or VaultName and SecretName",
+		},
+		{
+			name:          "app service reference missing parenthesis",
+			reference:     "@Microsoft.KeyVault(VaultName=myvault;SecretName=api-key",
+			errorContains: "missing closing parenthesis",
+		},
+		{
+			name:          "app service reference malformed pair",
+			reference:     "@Microsoft.KeyVault(VaultName)",
+			errorContains: "expected key=value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := parseSecretReference(tt.reference)
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("parseSecretReference(%s) error = %v; should contain %s", tt.reference, err, tt.errorContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSecretReference(%s) unexpected error: %v", tt.reference, err)
+			}
+			if ref != tt.expected {
+				t.Errorf("parseSecretReference(%s) = %+v; want %+v", tt.reference, ref, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsSecretReference(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected bool
+	}{
+		{"https://myvault.vault.azure.net/secrets/api-key", true},
+		{"@Microsoft.KeyVault(VaultName=myvault;SecretName=api-key)", true},
+		{"@microsoft.keyvault(VaultName=myvault;SecretName=api-key)", true},
+		{"api-key", false},
+		{"versions", false},
+	}
+
+	for _, tt := range tests {
+		if got := isSecretReference(tt.value); got != tt.expected {
+			t.Errorf("isSecretReference(%s) = %t; want %t", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestGetSecretWithReferences(t *testing.T) {
+	vault := newFakeVault("refs")
+	first := vault.add("api-key", "old-key")
+	vault.add("api-key", "new-key")
+	vault.add("db-password", "hunter2")
+	installFakeVaults(t, vault)
+
+	out, err := executeCommand(t,
+		"https://refs.vault.azure.net/secrets/api-key/"+first,
+		"@Microsoft.KeyVault(VaultName=refs;SecretName=api-key)",
+		"--vault-url", vault.url(), "--secret", "db-password",
+	)
+	if err != nil {
+		t.Fatalf("executeCommand() unexpected error: %v", err)
+	}
+	if expected := "old-key\nnew-key\nhunter2"; out != expected {
+		t.Errorf("executeCommand() output = %q; want %q", out, expected)
+	}
+}
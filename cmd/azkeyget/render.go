@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/spf13/cobra"
+)
+
+var (
+	renderOutput string
+	renderMode   string
+)
+
+func newRenderCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "render <template>",
+		Short: "Render a template file with secrets from Key Vault",
+		Long: `Render a Go text/template file, resolving secrets with these functions:
+
+  {{ secret "name" }}                  latest version of a secret
+  {{ secretVersion "name" "version" }} a specific version of a secret
+  {{ secretJSON "name" "path.to.field" }} a field of a secret holding JSON
+
+Secret names may also be full secret references. The result is written
+atomically to --out, or to stdout when --out is not set.`,
+		Args: usageArgs(cobra.ExactArgs(1)),
+		RunE: renderTemplate,
+	}
+
+	cmd.Flags().StringVar(&renderOutput, "out", "", "File to write the rendered output to (default: stdout)")
+	cmd.Flags().StringVar(&renderMode, "mode", "0600", "File mode of the output file, in octal")
+
+	return cmd
+}
+
+func renderTemplate(cmd *cobra.Command, args []string) error {
+	mode, err := parseFileMode(renderMode)
+	if err != nil {
+		return err
+	}
+
+	setupDebugLogging()
+	debugLog("Rendering template %s", args[0])
+
+	text, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read template: %w", err)
+	}
+
+	clients, err := newRetrievalClients()
+	if err != nil {
+		return err
+	}
+	resolver := newSecretResolver(context.Background(), clients)
+	rendered, err := resolver.render(filepath.Base(args[0]), string(text))
+	if err != nil {
+		return err
+	}
+
+	if renderOutput == "" || renderOutput == "-" {
+		_, err = cmd.OutOrStdout().Write(rendered)
+		return err
+	}
+	debugLog("Writing rendered template to %s with mode %04o", renderOutput, mode)
+	return writeFileAtomic(renderOutput, rendered, mode)
+}
+
+// secretResolver resolves secrets for templates, fetching each secret version
+// at most once. The secrets it resolved are kept in cache.
+type secretResolver struct {
+	ctx     context.Context
+	clients *vaultClients
+
+	mu    sync.Mutex
+	cache map[secretRef]azsecrets.Secret
+}
+
+func newSecretResolver(ctx context.Context, clients *vaultClients) *secretResolver {
+	return &secretResolver{ctx: ctx, clients: clients, cache: map[secretRef]azsecrets.Secret{}}
+}
+
+// render executes a template with the secret functions available.
+func (r *secretResolver) render(name, text string) ([]byte, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(template.FuncMap{
+		"secret":        r.secret,
+		"secretVersion": r.secretVersion,
+		"secretJSON":    r.secretJSON,
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, nil); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+func (r *secretResolver) secret(name string) (string, error) {
+	return r.secretVersion(name, "")
+}
+
+func (r *secretResolver) secretVersion(name, version string) (string, error) {
+	ref := secretRef{vault: vaultURL, name: name, version: version}
+	if isSecretReference(name) {
+		parsed, err := parseSecretReference(name)
+		if err != nil {
+			return "", err
+		}
+		ref = parsed
+		if version != "" {
+			ref.version = version
+		}
+	} else if vaultURL == "" {
+		return "", usageErrorf(`required flag(s) "vault-url" not set`)
+	}
+
+	secret, err := r.lookup(ref)
+	if err != nil {
+		return "", err
+	}
+	return *secret.Value, nil
+}
+
+// lookup returns the secret ref refers to, fetching it on first use.
+func (r *secretResolver) lookup(ref secretRef) (azsecrets.Secret, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if secret, ok := r.cache[ref]; ok {
+		return secret, nil
+	}
+	result := fetchSecret(r.ctx, r.clients, ref)
+	if result.err != nil {
+		return azsecrets.Secret{}, result.err
+	}
+	r.cache[ref] = result.secret
+	return result.secret, nil
+}
+
+func (r *secretResolver) secretJSON(name, path string) (string, error) {
+	value, err := r.secret(name)
+	if err != nil {
+		return "", err
+	}
+	return jsonField(value, path)
+}
+
+// jsonField extracts a dotted path such as "db.hosts.0" from a JSON document.
+// Strings are returned as-is and any other value is returned as JSON.
+func jsonField(document, path string) (string, error) {
+	var current any
+	if err := json.Unmarshal([]byte(document), &current); err != nil {
+		return "", fmt.Errorf("secret is not valid JSON: %w", err)
+	}
+
+	if path != "" {
+		for _, key := range strings.Split(path, ".") {
+			switch node := current.(type) {
+			case map[string]any:
+				value, ok := node[key]
+				if !ok {
+					return "", fmt.Errorf("field '%s' not found in path '%s'", key, path)
+				}
+				current = value
+			case []any:
+				index, err := strconv.Atoi(key)
+				if err != nil || index < 0 || index >= len(node) {
+					return "", fmt.Errorf("invalid index '%s' in path '%s'", key, path)
+				}
+				current = node[index]
+			default:
+				return "", fmt.Errorf("cannot look up '%s' in path '%s': not an object or array", key, path)
+			}
+		}
+	}
+
+	if s, ok := current.(string); ok {
+		return s, nil
+	}
+	encoded, err := json.Marshal(current)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// parseFileMode parses an octal file mode such as 0640.
+func parseFileMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, usageErrorf("invalid file mode '%s': expected octal permissions such as 0600", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers never observe a partially written file.
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		// No-op once the rename has succeeded
+		_ = os.Remove(tmpName)
+	}()
+
+	if err := tmp.Chmod(mode); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
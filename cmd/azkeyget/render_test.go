@@ -0,0 +1,161 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	vault := newFakeVault("render")
+	first := vault.add("db-password", "old")
+	vault.add("db-password", "new")
+	vault.add("config", `{"db": {"host": "db.internal", "ports": [5432, 6432]}, "debug": true}`)
+	installFakeVaults(t, vault)
+
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "app.conf.tmpl")
+	template := strings.Join([]string{
+		`password={{ secret "db-password" }}`,
+		`previous={{ secretVersion "db-password" "` + first + `" }}`,
+		`host={{ secretJSON "config" "db.host" }}`,
+		`port={{ secretJSON "config" "db.ports.1" }}`,
+		`debug={{ secretJSON "config" "debug" }}`,
+		`ref={{ secret "https://render.vault.azure.net/secrets/db-password" }}`,
+	}, "\n")
+	if err := os.WriteFile(templatePath, []byte(template), 0o600); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+
+	outPath := filepath.Join(dir, "app.conf")
+	if _, err := executeCommand(t, "render", templatePath, "--vault-url", vault.url(), "--out", outPath, "--mode", "0640"); err != nil {
+		t.Fatalf("render unexpected error: %v", err)
+	}
+
+	rendered, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("Failed to read rendered file: %v", err)
+	}
+	expected := "password=new\nprevious=old\nhost=db.internal\nport=6432\ndebug=true\nref=new"
+	if string(rendered) != expected {
+		t.Errorf("rendered = %q; want %q", rendered, expected)
+	}
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(outPath)
+		if err != nil {
+			t.Fatalf("Failed to stat rendered file: %v", err)
+		}
+		if info.Mode().Perm() != 0o640 {
+			t.Errorf("rendered file mode = %04o; want 0640", info.Mode().Perm())
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read directory: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("render left %d files behind; want the template and output only", len(entries))
+	}
+
+	// Each distinct secret version is fetched once, however often it is used
+	if got := vault.getCount(); got != 3 {
+		t.Errorf("GetSecret called %d times; want 3", got)
+	}
+}
+
+func TestRenderTemplateErrors(t *testing.T) {
+	vault := newFakeVault("rendererr")
+	vault.add("plain", "not json")
+	installFakeVaults(t, vault)
+
+	tests := []struct {
+		name          string
+		template      string
+		mode          string
+		errorContains string
+	}{
+		{
+			name:          "missing secret",
+			template:      `{{ secret "missing" }}`,
+			mode:          "0600",
+			errorContains: "failed to get secret 'missing'",
+		},
+		{
+			name:          "secret is not JSON",
+			template:      `{{ secretJSON "plain" "field" }}`,
+			mode:          "0600",
+			errorContains: "secret is not valid JSON",
+		},
+		{
+			name:          "template syntax error",
+			template:      `{{ secret "plain" `,
+			mode:          "0600",
+			errorContains: "failed to parse template",
+		},
+		{
+			name:          "invalid mode",
+			template:      `{{ secret "plain" }}`,
+			mode:          "rw-r--r--",
+			errorContains: "invalid file mode",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			templatePath := filepath.Join(dir, "tmpl")
+			if err := os.WriteFile(templatePath, []byte(tt.template), 0o600); err != nil {
+				t.Fatalf("Failed to write template: %v", err)
+			}
+			outPath := filepath.Join(dir, "out")
+
+			_, err := executeCommand(t, "render", templatePath, "--vault-url", vault.url(), "--out", outPath, "--mode", tt.mode)
+			if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+				t.Errorf("render error = %v; should contain %s", err, tt.errorContains)
+			}
+			if _, err := os.Stat(outPath); !os.IsNotExist(err) {
+				t.Errorf("render wrote %s despite the error", outPath)
+			}
+		})
+	}
+}
+
+func TestJSONField(t *testing.T) {
+	document := `{"a": {"b": [{"c": "deep"}, 2]}, "s": "text", "n": null}`
+	tests := []struct {
+		path          string
+		expected      string
+		errorContains string
+	}{
+		{path: "a.b.0.c", expected: "deep"},
+		{path: "a.b.1", expected: "2"},
+		{path: "s", expected: "text"},
+		{path: "n", expected: "null"},
+		{path: "a.b.0", expected: `{"c":"deep"}`},
+		{path: "", expected: `{"a":{"b":[{"c":"deep"},2]},"n":null,"s":"text"}`},
+		{path: "a.missing", errorContains: "field 'missing' not found"},
+		{path: "a.b.5", errorContains: "invalid index '5'"},
+		{path: "s.x", errorContains: "not an object or array"},
+	}
+
+	for _, tt := range tests {
+		got, err := jsonField(document, tt.path)
+		if tt.errorContains != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+				t.Errorf("jsonField(%s) error = %v; should contain %s", tt.path, err, tt.errorContains)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("jsonField(%s) unexpected error: %v", tt.path, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("jsonField(%s) = %s; want %s", tt.path, got, tt.expected)
+		}
+	}
+}
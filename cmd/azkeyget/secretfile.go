@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileSecret is a key and value read from a dotenv, JSON or YAML file.
+type fileSecret struct {
+	key   string
+	value string
+}
+
+// readSecretsFile reads key/value pairs from a dotenv, JSON or YAML file, in
+// file order, and returns them with the detected format. The format follows
+// the extension (.json, .yaml or .yml); anything else is parsed as dotenv.
+func readSecretsFile(path string) ([]fileSecret, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read '%s': %w", path, err)
+	}
+
+	format := "dotenv"
+	parse := parseDotenv
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		format, parse = "json", parseJSONSecrets
+	case ".yaml", ".yml":
+		format, parse = "yaml", parseYAMLSecrets
+	}
+	secrets, err := parse(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse '%s': %w", path, err)
+	}
+	return secrets, format, nil
+}
+
+// parseJSONSecrets accepts an object of string values, or a list of objects
+// with name and value fields as written by --output json.
+func parseJSONSecrets(data []byte) ([]fileSecret, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var outputs []struct {
+			Name  *string `json:"name"`
+			Value *string `json:"value"`
+		}
+		if err := json.Unmarshal(data, &outputs); err != nil {
+			return nil, err
+		}
+		secrets := make([]fileSecret, 0, len(outputs))
+		for i, output := range outputs {
+			if output.Name == nil || output.Value == nil {
+				return nil, fmt.Errorf("entry %d needs a name and a value", i+1)
+			}
+			secrets = append(secrets, fileSecret{key: *output.Name, value: *output.Value})
+		}
+		return secrets, nil
+	}
+
+	// Decode the keys in order so that results follow the file
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("expected an object of string values or a list of secrets")
+	}
+	var secrets []fileSecret
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := token.(string)
+		var value string
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("value of '%s' is not a string", key)
+		}
+		secrets = append(secrets, fileSecret{key: key, value: value})
+	}
+	return secrets, nil
+}
+
+// parseYAMLSecrets accepts a mapping of scalar values, or a list of mappings
+// with name and value fields as written by --output yaml.
+func parseYAMLSecrets(data []byte) ([]fileSecret, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+
+	root := document.Content[0]
+	switch root.Kind {
+	case yaml.SequenceNode:
+		var outputs []struct {
+			Name  *string `yaml:"name"`
+			Value *string `yaml:"value"`
+		}
+		if err := root.Decode(&outputs); err != nil {
+			return nil, err
+		}
+		secrets := make([]fileSecret, 0, len(outputs))
+		for i, output := range outputs {
+			if output.Name == nil || output.Value == nil {
+				return nil, fmt.Errorf("entry %d needs a name and a value", i+1)
+			}
+			secrets = append(secrets, fileSecret{key: *output.Name, value: *output.Value})
+		}
+		return secrets, nil
+	case yaml.MappingNode:
+		secrets := make([]fileSecret, 0, len(root.Content)/2)
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			key, value := root.Content[i].Value, root.Content[i+1]
+			if value.Kind != yaml.ScalarNode || value.Tag == "!!null" {
+				return nil, fmt.Errorf("value of '%s' is not a string", key)
+			}
+			secrets = append(secrets, fileSecret{key: key, value: value.Value})
+		}
+		return secrets, nil
+	default:
+		return nil, fmt.Errorf("expected a mapping of string values or a list of secrets")
+	}
+}
+
+// parseDotenv reads KEY=VALUE lines as written by --output dotenv or export.
+// Blank lines and comments are skipped and an export prefix is allowed.
+// Values may be unquoted, single-quoted (including the shell escaping of
+// --output export) or double-quoted with backslash escapes.
+// A quoted value may span lines, as multi-line values do in export output.
+func parseDotenv(data []byte) ([]fileSecret, error) {
+	var secrets []fileSecret
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		number := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, _, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", number)
+		}
+		// Keep trailing whitespace, which may be inside quotes
+		_, raw, _ := strings.Cut(lines[i], "=")
+		raw = strings.TrimLeft(raw, " \t")
+		value, err := parseDotenvValue(raw)
+		for errors.Is(err, errUnterminatedQuote) && i+1 < len(lines) {
+			i++
+			raw += "\n" + lines[i]
+			value, err = parseDotenvValue(raw)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number, err)
+		}
+		secrets = append(secrets, fileSecret{key: key, value: value})
+	}
+	return secrets, nil
+}
+
+// errUnterminatedQuote is returned for a value whose closing quote is missing.
+var errUnterminatedQuote = errors.New("unterminated quoted value")
+
+// parseDotenvValue unquotes a single dotenv value.
+func parseDotenvValue(raw string) (string, error) {
+	if raw == "" || (raw[0] != '\'' && raw[0] != '"') {
+		// An unquoted value ends at a comment
+		if i := strings.Index(raw, " #"); i >= 0 {
+			raw = raw[:i]
+		}
+		return strings.TrimSpace(raw), nil
+	}
+
+	var b strings.Builder
+	i := 0
+	for i < len(raw) {
+		switch raw[i] {
+		case '\'':
+			end := strings.IndexByte(raw[i+1:], '\'')
+			if end < 0 {
+				return "", errUnterminatedQuote
+			}
+			b.WriteString(raw[i+1 : i+1+end])
+			i += end + 2
+		case '"':
+			i++
+			for ; i < len(raw) && raw[i] != '"'; i++ {
+				if raw[i] != '\\' || i+1 == len(raw) {
+					b.WriteByte(raw[i])
+					continue
+				}
+				i++
+				switch raw[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 'r':
+					b.WriteByte('\r')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(raw[i])
+				}
+			}
+			if i == len(raw) {
+				return "", errUnterminatedQuote
+			}
+			i++
+		case '\\':
+			// The \' between single-quoted parts of a shell-quoted value
+			if i+1 < len(raw) {
+				b.WriteByte(raw[i+1])
+			}
+			i += 2
+		default:
+			rest := strings.TrimSpace(raw[i:])
+			if rest != "" && !strings.HasPrefix(rest, "#") {
+				return "", fmt.Errorf("unexpected text after quoted value")
+			}
+			return b.String(), nil
+		}
+	}
+	return b.String(), nil
+}
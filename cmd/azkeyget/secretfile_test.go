@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		expected      []fileSecret
+		errorContains string
+	}{
+		{
+			name:     "unquoted values, comments and export prefix",
+			content:  "# settings\n\nA=1\nexport B = two words # note\nC=\n",
+			expected: []fileSecret{{"A", "1"}, {"B", "two words"}, {"C", ""}},
+		},
+		{
+			name:     "single quotes keep their contents",
+			content:  `A='$HOME #not a comment'` + "\n",
+			expected: []fileSecret{{"A", "$HOME #not a comment"}},
+		},
+		{
+			name:     "shell-escaped single quote",
+			content:  `export A='it'\''s'` + "\n",
+			expected: []fileSecret{{"A", "it's"}},
+		},
+		{
+			name:     "double quote escapes",
+			content:  `A="line1\nline2 \"quoted\" \\ \$x"` + "\n",
+			expected: []fileSecret{{"A", "line1\nline2 \"quoted\" \\ $x"}},
+		},
+		{name: "missing equals", content: "A\n", errorContains: "line 1: expected KEY=VALUE"},
+		{name: "unterminated quote", content: "A=1\nB='x\n", errorContains: "line 2: unterminated quoted value"},
+		{name: "text after quotes", content: "A='x'y\n", errorContains: "unexpected text after quoted value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secrets, err := parseDotenv([]byte(tt.content))
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("parseDotenv() error = %v; should contain %s", err, tt.errorContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseDotenv() unexpected error: %v", err)
+			}
+			if len(secrets) != len(tt.expected) {
+				t.Fatalf("parseDotenv() = %v; want %v", secrets, tt.expected)
+			}
+			for i := range secrets {
+				if secrets[i] != tt.expected[i] {
+					t.Errorf("secret %d = %+v; want %+v", i, secrets[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+// TestParseDotenvRoundTrip reads back what --output dotenv and export write.
+func TestParseDotenvRoundTrip(t *testing.T) {
+	values := []string{"plain", "with space", "it's", "multi\nline\r\n", `back\slash "quoted" $VAR`, ""}
+	for _, format := range []string{"dotenv", "export"} {
+		t.Run(format, func(t *testing.T) {
+			var results []secretResult
+			for i, value := range values {
+				results = append(results, testResult(string(rune('a'+i)), value))
+			}
+			var out bytes.Buffer
+			if err := writeSecrets(&out, format, results); err != nil {
+				t.Fatalf("writeSecrets() unexpected error: %v", err)
+			}
+
+			secrets, err := parseDotenv(out.Bytes())
+			if err != nil {
+				t.Fatalf("parseDotenv() unexpected error: %v\n%s", err, out.String())
+			}
+			if len(secrets) != len(values) {
+				t.Fatalf("parseDotenv() returned %d secrets; want %d", len(secrets), len(values))
+			}
+			for i, secret := range secrets {
+				if secret.value != values[i] {
+					t.Errorf("%s = %q; want %q", secret.key, secret.value, values[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReadSecretsFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name          string
+		path          string
+		format        string
+		expected      []fileSecret
+		errorContains string
+	}{
+		{
+			name:     "json object keeps file order",
+			path:     write("object.json", `{"b": "2", "a": "1"}`),
+			format:   "json",
+			expected: []fileSecret{{"b", "2"}, {"a", "1"}},
+		},
+		{
+			name:     "json output list",
+			path:     write("list.json", `[{"name": "db-password", "value": "x", "version": "v1"}]`),
+			format:   "json",
+			expected: []fileSecret{{"db-password", "x"}},
+		},
+		{
+			name:     "yaml mapping keeps file order and scalar text",
+			path:     write("secrets.yaml", "port: 8080\nenabled: true\nname: \"quoted\"\n"),
+			format:   "yaml",
+			expected: []fileSecret{{"port", "8080"}, {"enabled", "true"}, {"name", "quoted"}},
+		},
+		{
+			name:     "yaml output list",
+			path:     write("list.yml", "- name: db-password\n  value: x\n"),
+			format:   "yaml",
+			expected: []fileSecret{{"db-password", "x"}},
+		},
+		{
+			name:     "anything else is dotenv",
+			path:     write(".env", "A=1\n"),
+			format:   "dotenv",
+			expected: []fileSecret{{"A", "1"}},
+		},
+		{name: "non-string json value", path: write("number.json", `{"a": 1}`), errorContains: "value of 'a' is not a string"},
+		{name: "json list entry without value", path: write("partial.json", `[{"name": "a"}]`), errorContains: "entry 1 needs a name and a value"},
+		{name: "null yaml value", path: write("null.yaml", "a: ~\n"), errorContains: "value of 'a' is not a string"},
+		{name: "nested yaml value", path: write("nested.yaml", "a:\n  b: c\n"), errorContains: "value of 'a' is not a string"},
+		{name: "missing file", path: filepath.Join(dir, "missing.env"), errorContains: "failed to read"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secrets, format, err := readSecretsFile(tt.path)
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("readSecretsFile() error = %v; should contain %s", err, tt.errorContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readSecretsFile() unexpected error: %v", err)
+			}
+			if format != tt.format {
+				t.Errorf("readSecretsFile() format = %s; want %s", format, tt.format)
+			}
+			if len(secrets) != len(tt.expected) {
+				t.Fatalf("readSecretsFile() = %v; want %v", secrets, tt.expected)
+			}
+			for i := range secrets {
+				if secrets[i] != tt.expected[i] {
+					t.Errorf("secret %d = %+v; want %+v", i, secrets[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
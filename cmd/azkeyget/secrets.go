@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+)
+
+// secretRef identifies a secret to retrieve. An empty version selects the
+// latest version.
+type secretRef struct {
+	vault   string
+	name    string
+	version string
+}
+
+// secretResult is the outcome of retrieving a single secret. env overrides
+// the environment variable name derived from the secret name.
+type secretResult struct {
+	name   string
+	env    string
+	secret azsecrets.Secret
+	err    error
+}
+
+// fetchSecrets retrieves the referenced secrets with at most workers requests
+// in flight. Results are returned in the same order as refs. When failFast is
+// set, the first failure cancels any requests that are still outstanding.
+func fetchSecrets(ctx context.Context, clients *vaultClients, refs []secretRef, workers int, failFast bool) []secretResult {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if workers < 1 {
+		workers = 1
+	}
+	workers = min(workers, len(refs))
+	debugLog("Retrieving %d secret(s) with %d worker(s)", len(refs), workers)
+
+	results := make([]secretResult, len(refs))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = fetchSecret(ctx, clients, refs[i])
+				if results[i].err != nil && failFast {
+					cancel()
+				}
+			}
+		}()
+	}
+	for i := range refs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+// fetchSecret retrieves a single secret version.
+func fetchSecret(ctx context.Context, clients *vaultClients, ref secretRef) secretResult {
+	name := ref.name
+	if err := ctx.Err(); err != nil {
+		return secretResult{name: name, err: fmt.Errorf("failed to get secret '%s': %w", name, err)}
+	}
+
+	if clients.agent != "" {
+		if result, ok := fetchFromAgent(ctx, clients.agent, ref); ok {
+			return result
+		}
+	}
+	if clients.cache != nil {
+		if secret, ok := clients.cache.get(ref); ok {
+			debugLog("Using cached secret: %s", name)
+			return secretResult{name: name, secret: secret}
+		}
+	}
+
+	client, err := clients.client(ref.vault)
+	if err != nil {
+		return secretResult{name: name, err: err}
+	}
+
+	if ref.version != "" {
+		debugLog("Retrieving secret: %s (version %s)", name, ref.version)
+	} else {
+		debugLog("Retrieving secret: %s", name)
+	}
+	response, err := client.GetSecret(ctx, name, ref.version, nil)
+	if err != nil {
+		debugLog("Failed to retrieve secret '%s': %v", name, err)
+		err = fmt.Errorf("failed to get secret '%s': %w", name, err)
+		// Cached versions of a secret that was deleted, disabled or made
+		// inaccessible must not be served later
+		if clients.cache != nil {
+			switch classifyError(err).Code {
+			case classNotFound.code, classDisabled.code, classForbidden.code:
+				clients.cache.forget(ref.vault, name)
+			}
+		}
+		return secretResult{name: name, err: err}
+	}
+	debugLog("Successfully retrieved secret: %s", name)
+
+	if response.Value == nil {
+		debugLog("Secret '%s' has no value", name)
+		return secretResult{name: name, err: fmt.Errorf("secret '%s' has no value", name)}
+	}
+	if clients.cache != nil {
+		clients.cache.put(ref, response.Secret)
+	}
+
+	return secretResult{name: name, secret: response.Secret}
+}
+
+// checkResults reports failed retrievals. A single failure is returned as-is so
+// that one-secret invocations behave exactly as before; multiple failures are
+// written to stderr and summarised. In fail-fast mode only the failure that
+// triggered cancellation is reported.
+func checkResults(results []secretResult, failFast bool) error {
+	var failed []secretResult
+	for _, result := range results {
+		if result.err != nil {
+			failed = append(failed, result)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	if failFast {
+		for _, result := range failed {
+			if !errors.Is(result.err, context.Canceled) {
+				return result.err
+			}
+		}
+		return failed[0].err
+	}
+
+	if len(results) == 1 {
+		return failed[0].err
+	}
+	summary := &retrievalError{total: len(results)}
+	for _, result := range failed {
+		reportError(os.Stderr, result.err)
+		summary.failed = append(summary.failed, result.err)
+	}
+	return summary
+}
+
+// retrievalError summarises the failures of a multi-secret operation. Its exit
+// code is the failure class shared by every failure, if there is one.
+type retrievalError struct {
+	failed []error
+	total  int
+	action string // verb for the message; "retrieve" when empty
+}
+
+func (e *retrievalError) Error() string {
+	action := e.action
+	if action == "" {
+		action = "retrieve"
+	}
+	return fmt.Sprintf("failed to %s %d of %d secrets", action, len(e.failed), e.total)
+}
+
+func (e *retrievalError) Unwrap() []error {
+	return e.failed
+}
+
+// writeSecretValues writes the value of every successfully retrieved secret,
+// one per line. A single secret is written without a trailing newline.
+func writeSecretValues(w io.Writer, results []secretResult) {
+	first := true
+	for _, result := range results {
+		if result.err != nil {
+			continue
+		}
+		if !first {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprint(w, *result.secret.Value)
+		first = false
+	}
+}
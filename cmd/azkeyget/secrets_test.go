@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+func TestFetchSecrets(t *testing.T) {
+	vault := newFakeVault("fetch")
+	vault.add("alpha", "one")
+	vault.add("beta", "two")
+	vault.add("gamma", "three")
+	installFakeVaults(t, vault)
+	clients := newVaultClients()
+
+	tests := []struct {
+		name     string
+		names    []string
+		workers  int
+		failFast bool
+		want     []string // expected values, "" marks a failure
+	}{
+		{
+			name:    "single secret",
+			names:   []string{"alpha"},
+			workers: 4,
+			want:    []string{"one"},
+		},
+		{
+			name:    "multiple secrets keep requested order",
+			names:   []string{"gamma", "alpha", "beta"},
+			workers: 2,
+			want:    []string{"three", "one", "two"},
+		},
+		{
+			name:    "missing secret does not abort the others",
+			names:   []string{"alpha", "missing", "beta"},
+			workers: 1,
+			want:    []string{"one", "", "two"},
+		},
+		{
+			name:    "zero workers still makes progress",
+			names:   []string{"beta"},
+			workers: 0,
+			want:    []string{"two"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := fetchSecrets(context.Background(), clients, refsFor(vault, tt.names...), tt.workers, tt.failFast)
+			if len(results) != len(tt.want) {
+				t.Fatalf("fetchSecrets() returned %d results; want %d", len(results), len(tt.want))
+			}
+			for i, result := range results {
+				if result.name != tt.names[i] {
+					t.Errorf("result[%d].name = %s; want %s", i, result.name, tt.names[i])
+				}
+				if tt.want[i] == "" {
+					if result.err == nil {
+						t.Errorf("result[%d] expected error but got none", i)
+					}
+					continue
+				}
+				if result.err != nil {
+					t.Errorf("result[%d] unexpected error: %v", i, result.err)
+					continue
+				}
+				if got := *result.secret.Value; got != tt.want[i] {
+					t.Errorf("result[%d] value = %s; want %s", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFetchSecretsFailFast(t *testing.T) {
+	vault := newFakeVault("failfast")
+	vault.add("alpha", "one")
+	installFakeVaults(t, vault)
+	clients := newVaultClients()
+
+	names := []string{"missing", "alpha", "alpha", "alpha"}
+	results := fetchSecrets(context.Background(), clients, refsFor(vault, names...), 1, true)
+
+	if got := vault.getCount(); got != 1 {
+		t.Errorf("GetSecret called %d times; want 1 after fail-fast", got)
+	}
+	err := checkResults(results, true)
+	if err == nil || !strings.Contains(err.Error(), "failed to get secret 'missing'") {
+		t.Errorf("checkResults() error = %v; want failure for 'missing'", err)
+	}
+}
+
+func TestCheckResults(t *testing.T) {
+	vault := newFakeVault("check")
+	vault.add("alpha", "one")
+	installFakeVaults(t, vault)
+	clients := newVaultClients()
+
+	tests := []struct {
+		name          string
+		names         []string
+		errorContains string
+	}{
+		{
+			name:  "all succeeded",
+			names: []string{"alpha"},
+		},
+		{
+			name:          "single failure is returned unchanged",
+			names:         []string{"missing"},
+			errorContains: "failed to get secret 'missing'",
+		},
+		{
+			name:          "multiple secrets are summarised",
+			names:         []string{"alpha", "missing", "other"},
+			errorContains: "failed to retrieve 2 of 3 secrets",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := fetchSecrets(context.Background(), clients, refsFor(vault, tt.names...), 2, false)
+			err := checkResults(results, false)
+			if tt.errorContains == "" {
+				if err != nil {
+					t.Errorf("checkResults() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+				t.Errorf("checkResults() error = %v; should contain %s", err, tt.errorContains)
+			}
+		})
+	}
+}
+
+func TestWriteSecretValues(t *testing.T) {
+	vault := newFakeVault("write")
+	vault.add("alpha", "one")
+	vault.add("beta", "two")
+	installFakeVaults(t, vault)
+	clients := newVaultClients()
+
+	tests := []struct {
+		name     string
+		names    []string
+		expected string
+	}{
+		{"single secret has no trailing newline", []string{"alpha"}, "one"},
+		{"multiple secrets are newline separated", []string{"alpha", "beta"}, "one\ntwo"},
+		{"failed secrets are skipped", []string{"alpha", "missing", "beta"}, "one\ntwo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			writeSecretValues(&out, fetchSecrets(context.Background(), clients, refsFor(vault, tt.names...), 2, false))
+			if out.String() != tt.expected {
+				t.Errorf("writeSecretValues() = %q; want %q", out.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestFetchSecretsVersion(t *testing.T) {
+	vault := newFakeVault("pinned")
+	first := vault.add("alpha", "one")
+	vault.add("alpha", "two")
+	installFakeVaults(t, vault)
+	clients := newVaultClients()
+
+	tests := []struct {
+		name     string
+		version  string
+		expected string
+	}{
+		{"latest version", "", "two"},
+		{"pinned version", first, "one"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refs := []secretRef{{vault: vault.url(), name: "alpha", version: tt.version}}
+			results := fetchSecrets(context.Background(), clients, refs, 1, false)
+			if results[0].err != nil {
+				t.Fatalf("fetchSecrets() unexpected error: %v", results[0].err)
+			}
+			if got := *results[0].secret.Value; got != tt.expected {
+				t.Errorf("fetchSecrets() value = %s; want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+// refsFor builds references to the latest version of each named secret.
+func refsFor(vault *fakeVault, names ...string) []secretRef {
+	refs := make([]secretRef, len(names))
+	for i, name := range names {
+		refs[i] = secretRef{vault: vault.url(), name: name}
+	}
+	return refs
+}
+
+func TestFetchSecretsAcrossVaults(t *testing.T) {
+	east := newFakeVault("east")
+	east.add("alpha", "east-one")
+	west := newFakeVault("west")
+	west.add("alpha", "west-one")
+	installFakeVaults(t, east, west)
+
+	credentials := 0
+	newCredential = countCredentials(newCredential, &credentials)
+
+	refs := append(refsFor(east, "alpha"), refsFor(west, "alpha")...)
+	results := fetchSecrets(context.Background(), newVaultClients(), refs, 2, false)
+
+	for i, want := range []string{"east-one", "west-one"} {
+		if results[i].err != nil {
+			t.Fatalf("result[%d] unexpected error: %v", i, results[i].err)
+		}
+		if got := *results[i].secret.Value; got != want {
+			t.Errorf("result[%d] value = %s; want %s", i, got, want)
+		}
+	}
+	if credentials != 1 {
+		t.Errorf("created %d credentials; want 1 shared between vaults", credentials)
+	}
+}
+
+// countCredentials wraps a credential factory to count its calls.
+func countCredentials(factory func(authSettings) (azcore.TokenCredential, error), count *int) func(authSettings) (azcore.TokenCredential, error) {
+	return func(settings authSettings) (azcore.TokenCredential, error) {
+		*count++
+		return factory(settings)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/spf13/cobra"
+)
+
+var (
+	setSecretName  string
+	setValue       string
+	setValueFile   string
+	setContentType string
+	setTags        map[string]string
+	setExpires     string
+	setNotBefore   string
+	setEnabled     bool
+)
+
+func newSetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set --secret <name> [--value <value> | --file <file>]",
+		Short: "Create a secret or add a new version of it",
+		Long: `Store a new version of a secret and print its version ID. The value is taken from
+--value, from --file, or otherwise from stdin, in which case a single trailing
+newline is removed. File contents are stored unchanged.
+
+--expires and --not-before accept an RFC 3339 time, a date such as 2025-12-31,
+or a duration from now such as 90d or 12h.`,
+		Args: usageArgs(cobra.NoArgs),
+		RunE: setSecret,
+	}
+
+	cmd.Flags().StringVarP(&setSecretName, "secret", "s", "", "Name or reference of the secret to set (required)")
+	cmd.Flags().StringVar(&setValue, "value", "", "Secret value (visible in shell history and process lists; prefer --file or stdin)")
+	cmd.Flags().StringVarP(&setValueFile, "file", "f", "", "File containing the secret value")
+	cmd.Flags().StringVar(&setContentType, "content-type", "", "Content type of the secret, e.g. text/plain or application/json")
+	cmd.Flags().StringToStringVar(&setTags, "tags", nil, "Tags as key=value, repeatable or comma-separated")
+	cmd.Flags().StringVar(&setExpires, "expires", "", "Expiry time of the new version")
+	cmd.Flags().StringVar(&setNotBefore, "not-before", "", "Time before which the new version cannot be used")
+	cmd.Flags().BoolVar(&setEnabled, "enabled", true, "Whether the new version is enabled")
+
+	return cmd
+}
+
+func setSecret(cmd *cobra.Command, _ []string) error {
+	if err := requireFlags(cmd, "secret"); err != nil {
+		return err
+	}
+	ref, err := writableSecretRef(cmd, setSecretName)
+	if err != nil {
+		return err
+	}
+
+	parameters, err := setSecretParameters(cmd)
+	if err != nil {
+		return err
+	}
+
+	setupDebugLogging()
+	debugLog("Setting secret '%s' in %s", ref.name, ref.vault)
+
+	client, err := newKeyVaultClient(ref.vault)
+	if err != nil {
+		return err
+	}
+	response, err := client.SetSecret(context.Background(), ref.name, parameters, nil)
+	if err != nil {
+		debugLog("Failed to set secret '%s': %v", ref.name, err)
+		return fmt.Errorf("failed to set secret '%s': %w", ref.name, err)
+	}
+	debugLog("Successfully set secret: %s", ref.name)
+	forgetChangedSecret(ref.vault, ref.name)
+
+	fmt.Fprintln(cmd.OutOrStdout(), response.ID.Version())
+	return nil
+}
+
+// writableSecretRef resolves the secret a write operation targets: a name in
+// --vault-url or a reference without a version.
+func writableSecretRef(cmd *cobra.Command, secret string) (secretRef, error) {
+	if !isSecretReference(secret) {
+		if err := requireFlags(cmd, "vault-url"); err != nil {
+			return secretRef{}, err
+		}
+		return secretRef{vault: vaultURL, name: secret}, nil
+	}
+	ref, err := parseSecretReference(secret)
+	if err != nil {
+		return secretRef{}, withClass(classUsage, err)
+	}
+	if ref.version != "" {
+		return secretRef{}, usageErrorf("secret reference '%s' includes a version; versions cannot be written", secret)
+	}
+	return ref, nil
+}
+
+// setSecretParameters builds the new secret version from the flags.
+func setSecretParameters(cmd *cobra.Command) (azsecrets.SetSecretParameters, error) {
+	value, err := readSetValue(cmd)
+	if err != nil {
+		return azsecrets.SetSecretParameters{}, err
+	}
+
+	now := time.Now()
+	attributes := &azsecrets.SecretAttributes{Enabled: &setEnabled}
+	if setExpires != "" {
+		expires, err := parseTimeFlag(setExpires, now)
+		if err != nil {
+			return azsecrets.SetSecretParameters{}, usageErrorf("invalid --expires: %w", err)
+		}
+		attributes.Expires = &expires
+	}
+	if setNotBefore != "" {
+		notBefore, err := parseTimeFlag(setNotBefore, now)
+		if err != nil {
+			return azsecrets.SetSecretParameters{}, usageErrorf("invalid --not-before: %w", err)
+		}
+		attributes.NotBefore = &notBefore
+	}
+
+	parameters := azsecrets.SetSecretParameters{
+		Value:            &value,
+		SecretAttributes: attributes,
+	}
+	if setContentType != "" {
+		parameters.ContentType = &setContentType
+	}
+	if len(setTags) > 0 {
+		parameters.Tags = make(map[string]*string, len(setTags))
+		for key, tag := range setTags {
+			parameters.Tags[key] = &tag
+		}
+	}
+	return parameters, nil
+}
+
+// readSetValue returns the value from exactly one of --value, --file or stdin.
+func readSetValue(cmd *cobra.Command) (string, error) {
+	valueGiven := cmd.Flags().Changed("value")
+	if valueGiven && setValueFile != "" {
+		return "", usageErrorf("only one of --value and --file may be given")
+	}
+
+	switch {
+	case valueGiven:
+		return setValue, nil
+	case setValueFile != "":
+		debugLog("Reading secret value from file: %s", setValueFile)
+		data, err := os.ReadFile(setValueFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret value: %w", err)
+		}
+		return string(data), nil
+	default:
+		if clientSecretStdin {
+			return "", usageErrorf("stdin cannot provide both the client secret and the secret value; use --value or --file")
+		}
+		if isTerminal(stdin) {
+			return "", usageErrorf("no secret value given; use --value or --file, or pipe the value to stdin")
+		}
+		debugLog("Reading secret value from stdin")
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret value from stdin: %w", err)
+		}
+		return trimTrailingNewline(string(data)), nil
+	}
+}
+
+// isTerminal reports whether r is an interactive terminal rather than a pipe
+// or file.
+func isTerminal(r io.Reader) bool {
+	file, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// parseTimeFlag parses an RFC 3339 time, a date, or a duration from now.
+func parseTimeFlag(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t.UTC(), nil
+	}
+	if d, err := parseDuration(s); err == nil {
+		return now.Add(d).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("'%s' is not a time (2006-01-02T15:04:05Z or 2006-01-02) or duration (90d, 12h)", s)
+}
+
+// parseDuration parses a Go duration, additionally accepting whole days such
+// as 30d.
+func parseDuration(s string) (time.Duration, error) {
+	if days, found := strings.CutSuffix(s, "d"); found {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration '%s'", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetSecret(t *testing.T) {
+	cleanTestEnvironment(t)
+	vault := newFakeVault("setter")
+	vault.add("existing", "old")
+	installFakeVaults(t, vault)
+
+	previousStdin := stdin
+	t.Cleanup(func() { stdin = previousStdin })
+
+	valueFile := filepath.Join(t.TempDir(), "cert.pem")
+	if err := os.WriteFile(valueFile, []byte("-----BEGIN CERTIFICATE-----\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write value file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		stdin    string
+		secret   string
+		expected string
+	}{
+		{
+			name:     "value flag",
+			args:     []string{"-v", vault.url(), "--secret", "existing", "--value", "new"},
+			secret:   "existing",
+			expected: "new",
+		},
+		{
+			name:     "file contents are stored unchanged",
+			args:     []string{"-v", vault.url(), "--secret", "cert", "--file", valueFile},
+			secret:   "cert",
+			expected: "-----BEGIN CERTIFICATE-----\n",
+		},
+		{
+			name:     "stdin loses one trailing newline",
+			args:     []string{"-v", vault.url(), "--secret", "piped"},
+			stdin:    "from stdin\n",
+			secret:   "piped",
+			expected: "from stdin",
+		},
+		{
+			name:     "secret reference needs no vault url",
+			args:     []string{"--secret", vault.url() + "secrets/by-reference", "--value", "referenced"},
+			secret:   "by-reference",
+			expected: "referenced",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdin = strings.NewReader(tt.stdin)
+			out, err := executeCommand(t, append([]string{"set"}, tt.args...)...)
+			if err != nil {
+				t.Fatalf("executeCommand() unexpected error: %v", err)
+			}
+			secret, ok := vault.latest(tt.secret)
+			if !ok {
+				t.Fatalf("secret '%s' was not stored", tt.secret)
+			}
+			if *secret.Value != tt.expected {
+				t.Errorf("stored value = %q; want %q", *secret.Value, tt.expected)
+			}
+			if out != secret.ID.Version()+"\n" {
+				t.Errorf("set printed %q; want the new version %s", out, secret.ID.Version())
+			}
+		})
+	}
+}
+
+func TestSetSecretMetadata(t *testing.T) {
+	cleanTestEnvironment(t)
+	vault := newFakeVault("metadata")
+	installFakeVaults(t, vault)
+
+	_, err := executeCommand(t, "set", "-v", vault.url(), "--secret", "api-key", "--value", "abc",
+		"--content-type", "text/plain", "--tags", "team=payments,env=prod", "--tags", "owner=ops",
+		"--expires", "2030-01-02", "--not-before", "2029-12-31T10:00:00+02:00", "--enabled=false")
+	if err != nil {
+		t.Fatalf("executeCommand() unexpected error: %v", err)
+	}
+
+	secret, _ := vault.latest("api-key")
+	if secret.ContentType == nil || *secret.ContentType != "text/plain" {
+		t.Errorf("content type = %v; want text/plain", secret.ContentType)
+	}
+	for key, want := range map[string]string{"team": "payments", "env": "prod", "owner": "ops"} {
+		if got := secret.Tags[key]; got == nil || *got != want {
+			t.Errorf("tag %s = %v; want %s", key, got, want)
+		}
+	}
+	if want := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC); secret.Attributes.Expires == nil || !secret.Attributes.Expires.Equal(want) {
+		t.Errorf("expires = %v; want %v", secret.Attributes.Expires, want)
+	}
+	if want := time.Date(2029, 12, 31, 8, 0, 0, 0, time.UTC); secret.Attributes.NotBefore == nil || !secret.Attributes.NotBefore.Equal(want) {
+		t.Errorf("not before = %v; want %v", secret.Attributes.NotBefore, want)
+	}
+	if *secret.Attributes.Enabled {
+		t.Errorf("enabled = true; want false")
+	}
+}
+
+func TestSetSecretErrors(t *testing.T) {
+	cleanTestEnvironment(t)
+	vault := newFakeVault("set-errors")
+	installFakeVaults(t, vault)
+
+	tests := []struct {
+		name          string
+		args          []string
+		errorContains string
+	}{
+		{"missing secret", []string{"set", "-v", vault.url(), "--value", "x"}, `required flag(s) "secret" not set`},
+		{"missing vault url", []string{"set", "--secret", "a", "--value", "x"}, `required flag(s) "vault-url" not set`},
+		{"value and file", []string{"set", "-v", vault.url(), "--secret", "a", "--value", "x", "--file", "f"}, "only one of --value and --file"},
+		{"versioned reference", []string{"set", "--secret", vault.url() + "secrets/a/0123", "--value", "x"}, "versions cannot be written"},
+		{"invalid expiry", []string{"set", "-v", vault.url(), "--secret", "a", "--value", "x", "--expires", "soon"}, "invalid --expires"},
+		{"value and client secret from stdin", []string{"set", "-v", vault.url(), "--secret", "a", "--client-secret-stdin"}, "stdin cannot provide both"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := executeCommand(t, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+				t.Errorf("executeCommand() error = %v; should contain %s", err, tt.errorContains)
+			}
+			if err != nil && classifyError(err).Code != classUsage.code {
+				t.Errorf("executeCommand() error %v is not a usage error", err)
+			}
+		})
+	}
+}
+
+func TestParseTimeFlag(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		input    string
+		expected time.Time
+		wantErr  bool
+	}{
+		{"2030-01-02T03:04:05Z", time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC), false},
+		{"2030-01-02", time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC), false},
+		{"90d", now.Add(90 * 24 * time.Hour), false},
+		{"12h", now.Add(12 * time.Hour), false},
+		{"-1d", time.Time{}, true},
+		{"tomorrow", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseTimeFlag(tt.input, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseTimeFlag(%q) expected error but got %v", tt.input, got)
+				}
+				return
+			}
+			if err != nil || !got.Equal(tt.expected) {
+				t.Errorf("parseTimeFlag(%q) = %v, %v; want %v", tt.input, got, err, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/spf13/cobra"
+)
+
+func newVersionsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "versions <secret>",
+		Short: "List the versions of a secret",
+		Long:  "List every version of a secret with its created, updated, enabled and expiry metadata, oldest first. The secret may be a name in --vault-url or a full secret reference.",
+		Args:  usageArgs(cobra.ExactArgs(1)),
+		RunE:  listVersions,
+	}
+}
+
+func listVersions(cmd *cobra.Command, args []string) error {
+	ref := secretRef{vault: vaultURL, name: args[0]}
+	if isSecretReference(args[0]) {
+		parsed, err := parseSecretReference(args[0])
+		if err != nil {
+			return withClass(classUsage, err)
+		}
+		ref = parsed
+	} else if err := requireFlags(cmd, "vault-url"); err != nil {
+		return err
+	}
+
+	setupDebugLogging()
+	debugLog("Listing versions of secret '%s' in %s", ref.name, ref.vault)
+
+	client, err := newKeyVaultClient(ref.vault)
+	if err != nil {
+		return err
+	}
+
+	versions, err := fetchVersions(context.Background(), client, ref.name)
+	if err != nil {
+		return err
+	}
+	debugLog("Found %d version(s) of secret '%s'", len(versions), ref.name)
+
+	return writeVersions(cmd.OutOrStdout(), versions)
+}
+
+// fetchVersions pages through every version of a secret and returns them
+// sorted by creation time, oldest first.
+func fetchVersions(ctx context.Context, client *azsecrets.Client, name string) ([]*azsecrets.SecretProperties, error) {
+	var versions []*azsecrets.SecretProperties
+	pager := client.NewListSecretPropertiesVersionsPager(name, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			debugLog("Failed to list versions of secret '%s': %v", name, err)
+			return nil, fmt.Errorf("failed to list versions of secret '%s': %w", name, err)
+		}
+		versions = append(versions, page.Value...)
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return attributeTime(versions[i].Attributes, created).Before(attributeTime(versions[j].Attributes, created))
+	})
+	return versions, nil
+}
+
+// writeVersions prints one row per secret version.
+func writeVersions(w io.Writer, versions []*azsecrets.SecretProperties) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tENABLED\tCREATED\tUPDATED\tEXPIRES")
+	for _, props := range versions {
+		enabled := "-"
+		if props.Attributes != nil && props.Attributes.Enabled != nil {
+			enabled = fmt.Sprintf("%t", *props.Attributes.Enabled)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			props.ID.Version(),
+			enabled,
+			formatAttributeTime(props.Attributes, created),
+			formatAttributeTime(props.Attributes, updated),
+			formatAttributeTime(props.Attributes, expires),
+		)
+	}
+	return tw.Flush()
+}
+
+// attributeField selects one of the timestamps in SecretAttributes.
+type attributeField int
+
+const (
+	created attributeField = iota
+	updated
+	expires
+	notBefore
+)
+
+// attributeTime returns the selected timestamp, or the zero time when unset.
+func attributeTime(attrs *azsecrets.SecretAttributes, field attributeField) time.Time {
+	if attrs == nil {
+		return time.Time{}
+	}
+	var value *time.Time
+	switch field {
+	case created:
+		value = attrs.Created
+	case updated:
+		value = attrs.Updated
+	case expires:
+		value = attrs.Expires
+	case notBefore:
+		value = attrs.NotBefore
+	}
+	if value == nil {
+		return time.Time{}
+	}
+	return *value
+}
+
+// formatAttributeTime formats the selected timestamp as RFC 3339, or "-" when unset.
+func formatAttributeTime(attrs *azsecrets.SecretAttributes, field attributeField) string {
+	value := attributeTime(attrs, field)
+	if value.IsZero() {
+		return "-"
+	}
+	return value.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+)
+
+func TestFetchVersions(t *testing.T) {
+	vault := newFakeVault("versions")
+	first := vault.add("alpha", "one")
+	second := vault.add("alpha", "two")
+	installFakeVaults(t, vault)
+	client := newFakeClient(t, vault)
+
+	versions, err := fetchVersions(context.Background(), client, "alpha")
+	if err != nil {
+		t.Fatalf("fetchVersions() unexpected error: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("fetchVersions() returned %d versions; want 2", len(versions))
+	}
+	if versions[0].ID.Version() != first || versions[1].ID.Version() != second {
+		t.Errorf("fetchVersions() = [%s %s]; want [%s %s]",
+			versions[0].ID.Version(), versions[1].ID.Version(), first, second)
+	}
+}
+
+func TestWriteVersions(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	id := azsecrets.ID("https://example.vault.azure.net/secrets/alpha/abc123")
+	versions := []*azsecrets.SecretProperties{
+		{
+			ID: &id,
+			Attributes: &azsecrets.SecretAttributes{
+				Enabled: to.Ptr(false),
+				Created: &created,
+			},
+		},
+	}
+
+	var out bytes.Buffer
+	if err := writeVersions(&out, versions); err != nil {
+		t.Fatalf("writeVersions() unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("writeVersions() wrote %d lines; want 2:\n%s", len(lines), out.String())
+	}
+	if fields := strings.Fields(lines[0]); strings.Join(fields, " ") != "VERSION ENABLED CREATED UPDATED EXPIRES" {
+		t.Errorf("writeVersions() header = %q", lines[0])
+	}
+	if fields := strings.Fields(lines[1]); strings.Join(fields, " ") != "abc123 false 2024-01-02T03:04:05Z - -" {
+		t.Errorf("writeVersions() row = %q", lines[1])
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchTemplates     []string
+	watchSecretNames   []string
+	watchOutput        string
+	watchOut           string
+	watchInterval      string
+	watchMode          string
+	watchReloadCommand string
+	watchSignal        string
+	watchPID           int
+	watchPIDFile       string
+)
+
+// watchContext returns the context watch runs in, which ends on SIGINT or
+// SIGTERM. Tests replace it.
+var watchContext = func() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// watchTarget is a file kept up to date by watch: a rendered template, or the
+// watched secrets in an output format when template is empty.
+type watchTarget struct {
+	template string
+	path     string
+}
+
+// watcher re-renders its targets when a secret they use gets a new version,
+// then reloads the application using them.
+type watcher struct {
+	clients *vaultClients
+	targets []watchTarget
+	refs    []secretRef // secrets written to the secrets file, if any
+	format  string
+	mode    os.FileMode
+	out     io.Writer
+	errOut  io.Writer
+
+	reloadCommand string
+	signal        os.Signal
+	pid           int
+	pidFile       string
+
+	// versions holds the version ID of every secret the targets use in
+	// its latest version. Pinned versions never change and are not polled.
+	versions map[secretRef]string
+}
+
+func newWatchCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Keep files up to date as secrets rotate",
+		Long: `Render templates and write secrets to files, then poll Key Vault every
+--interval for new versions of the secrets they use. When a secret changes,
+every affected file is rewritten atomically and the application using them is
+reloaded, by running --reload-command with the shell and/or sending --signal
+to the process in --pid or --pid-file. Nothing is reloaded on start.
+
+Files are only rewritten when their content changes, and are left as they are
+when a secret cannot be retrieved. Watch stops on SIGINT or SIGTERM.`,
+		Example: `  azkeyget watch -v https://myvault.vault.azure.net/ \
+    --template app.conf.tmpl=/etc/app/app.conf \
+    --secret db-password --out /run/secrets/app.env \
+    --pid-file /run/app.pid --signal HUP`,
+		Args: usageArgs(cobra.NoArgs),
+		RunE: runWatch,
+	}
+
+	cmd.Flags().StringArrayVarP(&watchTemplates, "template", "t", nil, "Template to render as TEMPLATE=OUT (repeatable)")
+	cmd.Flags().StringSliceVarP(&watchSecretNames, "secret", "s", nil, "Secret name to write to --out, repeatable or comma-separated")
+	cmd.Flags().StringVarP(&watchOutput, "output", "o", "dotenv", "Format of --out: raw, json, yaml, dotenv, export, k8s-secret")
+	addK8sSecretFlags(cmd)
+	cmd.Flags().StringVar(&watchOut, "out", "", "File to write the secrets given by --secret to")
+	cmd.Flags().StringVar(&watchInterval, "interval", "1m", "How often to check for new secret versions")
+	cmd.Flags().StringVar(&watchMode, "mode", "0600", "File mode of the written files, in octal")
+	cmd.Flags().StringVar(&watchReloadCommand, "reload-command", "", "Shell command to run after files changed")
+	cmd.Flags().StringVar(&watchSignal, "signal", "HUP", "Signal to send to --pid or --pid-file after files changed")
+	cmd.Flags().IntVar(&watchPID, "pid", 0, "Process to signal after files changed")
+	cmd.Flags().StringVar(&watchPIDFile, "pid-file", "", "File holding the PID of the process to signal, read on every reload")
+
+	return cmd
+}
+
+func runWatch(cmd *cobra.Command, _ []string) error {
+	w, interval, err := newWatcher(cmd)
+	if err != nil {
+		return err
+	}
+
+	setupDebugLogging()
+	debugLog("Watching %d file(s) every %s", len(w.targets), interval)
+
+	ctx, stop := watchContext()
+	defer stop()
+	if _, err := w.render(ctx, nil); err != nil {
+		return err
+	}
+	return w.run(ctx, interval)
+}
+
+// newWatcher validates the watch flags and returns the watcher they describe
+// with its polling interval.
+func newWatcher(cmd *cobra.Command) (*watcher, time.Duration, error) {
+	if len(watchTemplates) == 0 && len(watchSecretNames) == 0 {
+		return nil, 0, usageErrorf("at least one --template or --secret is required")
+	}
+	if (len(watchSecretNames) > 0) != (watchOut != "") {
+		return nil, 0, usageErrorf("--secret and --out must be used together")
+	}
+	if err := validateOutputFormat(watchOutput); err != nil {
+		return nil, 0, err
+	}
+	if err := validateK8sSecretFlags(watchOutput); err != nil {
+		return nil, 0, err
+	}
+	interval, err := parseDuration(watchInterval)
+	if err != nil || interval <= 0 {
+		return nil, 0, usageErrorf("invalid --interval '%s': expected a duration such as 30s, 5m or 1h", watchInterval)
+	}
+	mode, err := parseFileMode(watchMode)
+	if err != nil {
+		return nil, 0, err
+	}
+	if watchPID < 0 {
+		return nil, 0, usageErrorf("invalid --pid %d: expected the PID of a single process", watchPID)
+	}
+	if watchPID != 0 && watchPIDFile != "" {
+		return nil, 0, usageErrorf("--pid and --pid-file cannot be used together")
+	}
+
+	w := &watcher{
+		clients:       newVaultClients(),
+		format:        watchOutput,
+		mode:          mode,
+		out:           cmd.OutOrStdout(),
+		errOut:        cmd.ErrOrStderr(),
+		reloadCommand: watchReloadCommand,
+		pid:           watchPID,
+		pidFile:       watchPIDFile,
+	}
+	if watchPID != 0 || watchPIDFile != "" {
+		if w.signal, err = parseSignal(watchSignal); err != nil {
+			return nil, 0, err
+		}
+	} else if cmd.Flags().Changed("signal") {
+		return nil, 0, usageErrorf("--signal requires --pid or --pid-file")
+	}
+
+	for _, spec := range watchTemplates {
+		template, path, found := strings.Cut(spec, "=")
+		if !found || template == "" || path == "" {
+			return nil, 0, usageErrorf("invalid --template '%s': expected TEMPLATE=OUT", spec)
+		}
+		w.targets = append(w.targets, watchTarget{template: template, path: path})
+	}
+	if watchOut != "" {
+		if err := requireFlags(cmd, "vault-url"); err != nil {
+			return nil, 0, err
+		}
+		for _, name := range watchSecretNames {
+			w.refs = append(w.refs, secretRef{vault: vaultURL, name: name})
+		}
+		w.targets = append(w.targets, watchTarget{path: watchOut})
+	}
+	return w, interval, nil
+}
+
+// run polls for new secret versions every interval until ctx is done. Failed
+// polls are reported and retried at the next interval.
+func (w *watcher) run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			debugLog("Watch stopped")
+			return nil
+		case <-ticker.C:
+			if err := w.poll(ctx); err != nil && ctx.Err() == nil {
+				reportError(w.errOut, err)
+			}
+		}
+	}
+}
+
+// poll retrieves the latest version of every watched secret and, when one has
+// changed, rewrites the targets and reloads.
+func (w *watcher) poll(ctx context.Context) error {
+	refs := make([]secretRef, 0, len(w.versions))
+	for ref := range w.versions {
+		refs = append(refs, ref)
+	}
+	slices.SortFunc(refs, func(a, b secretRef) int {
+		return strings.Compare(a.vault+"\x00"+a.name, b.vault+"\x00"+b.name)
+	})
+	if len(refs) == 0 {
+		return nil
+	}
+	debugLog("Checking %d secret(s) for new versions", len(refs))
+
+	results := fetchSecrets(ctx, w.clients, refs, concurrency, true)
+	if err := checkResults(results, true); err != nil {
+		return err
+	}
+	changed := false
+	latest := make(map[secretRef]azsecrets.Secret, len(results))
+	for i, result := range results {
+		latest[refs[i]] = result.secret
+		if secretVersionID(result.secret) != w.versions[refs[i]] {
+			fmt.Fprintf(w.out, "Secret '%s' changed\n", result.name)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	written, err := w.render(ctx, latest)
+	if err != nil || written == 0 {
+		return err
+	}
+	return w.reload()
+}
+
+// render writes every target whose content changed and records the versions
+// of the secrets used. Secrets in latest are used as retrieved; others are
+// fetched. Nothing is written unless every target could be rendered, so that
+// the files never mix old and new secrets.
+func (w *watcher) render(ctx context.Context, latest map[secretRef]azsecrets.Secret) (int, error) {
+	resolver := newSecretResolver(ctx, w.clients)
+	for ref, secret := range latest {
+		resolver.cache[ref] = secret
+	}
+
+	contents := make([][]byte, len(w.targets))
+	for i, target := range w.targets {
+		content, err := w.content(resolver, target)
+		if err != nil {
+			return 0, err
+		}
+		contents[i] = content
+	}
+
+	written := 0
+	for i, target := range w.targets {
+		current, err := os.ReadFile(target.path)
+		if err == nil && bytes.Equal(current, contents[i]) {
+			debugLog("%s is up to date", target.path)
+			continue
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return written, fmt.Errorf("failed to read %s: %w", target.path, err)
+		}
+		if err := writeFileAtomic(target.path, contents[i], w.mode); err != nil {
+			return written, err
+		}
+		fmt.Fprintf(w.out, "Wrote %s\n", target.path)
+		written++
+	}
+
+	w.versions = map[secretRef]string{}
+	for ref, secret := range resolver.cache {
+		if ref.version == "" {
+			w.versions[ref] = secretVersionID(secret)
+		}
+	}
+	return written, nil
+}
+
+// content renders a target. The template is read again each time, so that
+// edits to it are picked up with the next change.
+func (w *watcher) content(resolver *secretResolver, target watchTarget) ([]byte, error) {
+	if target.template != "" {
+		text, err := os.ReadFile(target.template)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template: %w", err)
+		}
+		return resolver.render(filepath.Base(target.template), string(text))
+	}
+
+	results := make([]secretResult, len(w.refs))
+	for i, ref := range w.refs {
+		secret, err := resolver.lookup(ref)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = secretResult{name: ref.name, secret: secret}
+	}
+	var out bytes.Buffer
+	if err := writeSecrets(&out, w.format, results); err != nil {
+		return nil, fmt.Errorf("failed to write output: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// reload runs the reload command and signals the application, if configured.
+func (w *watcher) reload() error {
+	if w.reloadCommand != "" {
+		debugLog("Running reload command: %s", w.reloadCommand)
+		child := shellCommand(w.reloadCommand)
+		child.Stdout = w.out
+		child.Stderr = w.errOut
+		if err := child.Run(); err != nil {
+			return fmt.Errorf("reload command failed: %w", err)
+		}
+	}
+	if w.signal == nil {
+		return nil
+	}
+
+	pid := w.pid
+	if w.pidFile != "" {
+		data, err := os.ReadFile(w.pidFile)
+		if err != nil {
+			return fmt.Errorf("failed to read PID file: %w", err)
+		}
+		if pid, err = strconv.Atoi(strings.TrimSpace(string(data))); err != nil || pid <= 0 {
+			return fmt.Errorf("PID file '%s' does not hold a process ID", w.pidFile)
+		}
+	}
+	debugLog("Sending %v to process %d", w.signal, pid)
+	if err := signalProcess(pid, w.signal); err != nil {
+		return err
+	}
+	fmt.Fprintf(w.out, "Signalled process %d\n", pid)
+	return nil
+}
+
+// secretVersionID returns the version of a secret, or "" if it has no ID.
+func secretVersionID(secret azsecrets.Secret) string {
+	if secret.ID == nil {
+		return ""
+	}
+	return secret.ID.Version()
+}
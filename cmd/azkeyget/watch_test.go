@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startWatch runs watch with args until the returned function is called,
+// which stops it and returns its output.
+func startWatch(t *testing.T, args ...string) func() string {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	previous := watchContext
+	t.Cleanup(func() { watchContext = previous })
+	watchContext = func() (context.Context, context.CancelFunc) { return ctx, cancel }
+
+	type outcome struct {
+		out string
+		err error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		out, err := executeCommand(t, append([]string{"watch", "--interval", "10ms"}, args...)...)
+		done <- outcome{out, err}
+	}()
+	stopped := false
+	stop := func() string {
+		if stopped {
+			return ""
+		}
+		stopped = true
+		cancel()
+		result := <-done
+		if result.err != nil {
+			t.Errorf("watch unexpected error: %v", result.err)
+		}
+		return result.out
+	}
+	t.Cleanup(func() { stop() })
+	return stop
+}
+
+// waitForFile waits until the file at path holds expected.
+func waitForFile(t *testing.T, path, expected string) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, err := os.ReadFile(path)
+		if err == nil && string(data) == expected {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s = %q, %v; want %q", path, data, err, expected)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	cleanTestEnvironment(t)
+	vault := newFakeVault("watch")
+	pinned := vault.add("db-password", "old")
+	vault.add("api-key", "key")
+	installFakeVaults(t, vault)
+
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "app.conf.tmpl")
+	template := `password={{ secret "db-password" }} first={{ secretVersion "db-password" "` + pinned + `" }}`
+	if err := os.WriteFile(templatePath, []byte(template), 0o600); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	confPath := filepath.Join(dir, "app.conf")
+	envPath := filepath.Join(dir, "app.env")
+	markerPath := filepath.Join(dir, "reloads")
+
+	args := []string{"-v", vault.url(), "--template", templatePath + "=" + confPath, "-s", "api-key", "--out", envPath}
+	if runtime.GOOS != "windows" {
+		args = append(args, "--reload-command", "echo reload >> "+markerPath)
+	}
+	stop := startWatch(t, args...)
+	waitForFile(t, confPath, "password=old first=old")
+	waitForFile(t, envPath, "API_KEY=key\n")
+
+	vault.add("db-password", "new")
+	waitForFile(t, confPath, "password=new first=old")
+	if runtime.GOOS != "windows" {
+		waitForFile(t, markerPath, "reload\n")
+	}
+
+	out := stop()
+	for _, line := range []string{"Wrote " + confPath, "Wrote " + envPath, "Secret 'db-password' changed"} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("watch output = %q; want a line %q", out, line)
+		}
+	}
+	// The secrets file did not change and was written once
+	if n := strings.Count(out, "Wrote "+envPath); n != 1 {
+		t.Errorf("watch wrote %s %d times; want 1", envPath, n)
+	}
+	if info, err := os.Stat(envPath); err == nil && runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Errorf("%s mode = %04o; want 0600", envPath, info.Mode().Perm())
+	}
+}
+
+func TestWatchKeepsFilesOnFailure(t *testing.T) {
+	cleanTestEnvironment(t)
+	vault := newFakeVault("watch-failure")
+	vault.add("db-password", "old")
+	installFakeVaults(t, vault)
+
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "app.conf.tmpl")
+	if err := os.WriteFile(templatePath, []byte(`{{ secret "db-password" }}`), 0o600); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	confPath := filepath.Join(dir, "app.conf")
+	stop := startWatch(t, "-v", vault.url(), "--template", templatePath+"="+confPath)
+	waitForFile(t, confPath, "old")
+
+	// A template that no longer renders leaves the file as it was, and a
+	// later fix is picked up with the next change
+	if err := os.WriteFile(templatePath, []byte(`{{ secret "missing" }}`), 0o600); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	vault.add("db-password", "new")
+	for gets := vault.getCount(); vault.getCount() < gets+3; {
+		time.Sleep(5 * time.Millisecond)
+	}
+	waitForFile(t, confPath, "old")
+
+	if err := os.WriteFile(templatePath, []byte(`{{ secret "db-password" }}`), 0o600); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	vault.add("db-password", "newer")
+	waitForFile(t, confPath, "newer")
+	stop()
+}
+
+func TestWatchUsageErrors(t *testing.T) {
+	cleanTestEnvironment(t)
+
+	tests := []struct {
+		name          string
+		args          []string
+		errorContains string
+		unixOnly      bool
+	}{
+		{name: "nothing to watch", args: []string{}, errorContains: "at least one --template or --secret"},
+		{name: "secret without out", args: []string{"-s", "x"}, errorContains: "--secret and --out must be used together"},
+		{name: "out without secret", args: []string{"--template", "a.tmpl=a", "--out", "x.env"}, errorContains: "--secret and --out must be used together"},
+		{name: "malformed template", args: []string{"--template", "app.tmpl"}, errorContains: "expected TEMPLATE=OUT"},
+		{name: "invalid interval", args: []string{"-s", "x", "--out", "x.env", "--interval", "0"}, errorContains: "invalid --interval"},
+		{name: "invalid format", args: []string{"-s", "x", "--out", "x.env", "-o", "xml"}, errorContains: "unsupported output format"},
+		{name: "invalid mode", args: []string{"-s", "x", "--out", "x.env", "--mode", "999"}, errorContains: "invalid file mode"},
+		{name: "signal without pid", args: []string{"-s", "x", "--out", "x.env", "--signal", "USR1"}, errorContains: "--signal requires --pid or --pid-file"},
+		{name: "negative pid", args: []string{"-s", "x", "--out", "x.env", "--pid=-1234"}, errorContains: "invalid --pid -1234"},
+		{name: "pid and pid file", args: []string{"-s", "x", "--out", "x.env", "--pid", "1", "--pid-file", "app.pid"}, errorContains: "cannot be used together"},
+		{name: "unknown signal", args: []string{"-s", "x", "--out", "x.env", "--pid", "1", "--signal", "BOGUS"}, errorContains: "unsupported signal 'BOGUS'", unixOnly: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.unixOnly && runtime.GOOS == "windows" {
+				t.Skip("signals are not supported on Windows")
+			}
+			args := append([]string{"watch", "-v", "https://vault.vault.azure.net/"}, tt.args...)
+			_, err := executeCommand(t, args...)
+			if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+				t.Fatalf("error = %v; want it to contain %q", err, tt.errorContains)
+			}
+			if classifyError(err).Code != classUsage.code {
+				t.Errorf("error %v is not a usage error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWatchSignal(t *testing.T) {
+	cleanTestEnvironment(t)
+	vault := newFakeVault("watch-signal")
+	vault.add("db-password", "old")
+	installFakeVaults(t, vault)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	defer signal.Stop(signals)
+
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "app.pid")
+	if err := os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write PID file: %v", err)
+	}
+	envPath := filepath.Join(dir, "app.env")
+	startWatch(t, "-v", vault.url(), "-s", "db-password", "--out", envPath, "-o", "raw", "--pid-file", pidFile, "--signal", "SIGUSR1")
+	waitForFile(t, envPath, "old")
+
+	vault.add("db-password", "new")
+	select {
+	case <-signals:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch did not signal the process")
+	}
+	waitForFile(t, envPath, "new")
+}